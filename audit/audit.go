@@ -0,0 +1,389 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record запись журнала аудита. Hash считается от PrevHash и содержимого
+// записи, поэтому изменение или удаление любой записи ломает цепочку.
+type Record struct {
+	Seq      int64           `json:"seq"`
+	Time     time.Time       `json:"time"`
+	Actor    string          `json:"actor"`
+	Action   string          `json:"action"`
+	QuoteID  int             `json:"quote_id"`
+	Before   json.RawMessage `json:"before,omitempty"`
+	After    json.RawMessage `json:"after,omitempty"`
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash"`
+}
+
+type Filter struct {
+	Actor   string
+	Action  string
+	QuoteID int
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+func (f Filter) match(record Record) bool {
+	switch {
+	case f.Actor != "" && record.Actor != f.Actor:
+		return false
+	case f.Action != "" && record.Action != f.Action:
+		return false
+	case f.QuoteID != 0 && record.QuoteID != f.QuoteID:
+		return false
+	case !f.Since.IsZero() && record.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && record.Time.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// Log журнал аудита в формате JSON Lines, только на дозапись.
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	seq      int64
+	lastHash string
+	onError  func(record Record, err error)
+
+	// Очередь фоновой записи, см. Enqueue. queueMu защищает отправку в
+	// очередь от ее закрытия в Close.
+	queueMu sync.RWMutex
+	queue   chan item
+	closed  bool
+	stopped chan struct{}
+}
+
+// item элемент очереди фоновой записи: запись журнала или отметка Flush.
+type item struct {
+	record  Record
+	flushed chan struct{}
+}
+
+// queueSize размер очереди и наибольшее число записей на один fsync.
+const queueSize = 1024
+
+func Open(path string) (*Log, error) {
+	l := &Log{path: path}
+
+	err := readRecords(path, func(record Record) error {
+		l.seq = record.Seq
+		l.lastHash = record.Hash
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	l.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть журнал аудита: %w", err)
+	}
+
+	l.queue = make(chan item, queueSize)
+	l.stopped = make(chan struct{})
+	go l.run()
+
+	return l, nil
+}
+
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append дописывает запись в конец цепочки и возвращает ее с заполненными
+// Seq, Time и хешами.
+func (l *Log) Append(record Record) (Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return Record{}, os.ErrClosed
+	}
+
+	record, line, err := seal(record, l.seq+1, l.lastHash)
+	if err != nil {
+		return Record{}, err
+	}
+	if err = l.write(line); err != nil {
+		return Record{}, err
+	}
+
+	l.seq = record.Seq
+	l.lastHash = record.Hash
+
+	return record, nil
+}
+
+// OnError задает обработчик ошибок фоновой записи, см. Enqueue.
+func (l *Log) OnError(fn func(record Record, err error)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.onError = fn
+}
+
+// Enqueue ставит запись в очередь фоновой записи и возвращается, не дожидаясь
+// диска. Записи попадают в цепочку в порядке вызовов Enqueue, Seq и хеши
+// заполняются при записи, а fsync выполняется один раз на пачку записей.
+// Ошибки записи передаются обработчику OnError.
+func (l *Log) Enqueue(record Record) {
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	l.send(item{record: record})
+}
+
+// Flush ждет, пока записи, поставленные в очередь до вызова, будут записаны.
+func (l *Log) Flush() {
+	flushed := make(chan struct{})
+	if l.send(item{flushed: flushed}) {
+		<-flushed
+	}
+}
+
+func (l *Log) send(it item) bool {
+	l.queueMu.RLock()
+	defer l.queueMu.RUnlock()
+
+	if l.closed {
+		return false
+	}
+	l.queue <- it
+	return true
+}
+
+// run записывает очередь пачками, пока она не будет закрыта в Close.
+func (l *Log) run() {
+	defer close(l.stopped)
+
+	for it := range l.queue {
+		batch := []item{it}
+	drain:
+		for len(batch) < queueSize {
+			select {
+			case next, ok := <-l.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		l.writeBatch(batch)
+	}
+}
+
+func (l *Log) writeBatch(batch []item) {
+	l.mu.Lock()
+
+	var failed []Record
+	var errs []error
+	var data []byte
+	var written []Record
+	seq, lastHash := l.seq, l.lastHash
+	for _, it := range batch {
+		if it.flushed != nil {
+			continue
+		}
+		record, line, err := seal(it.record, seq+1, lastHash)
+		if err != nil {
+			failed, errs = append(failed, it.record), append(errs, err)
+			continue
+		}
+		data = append(data, line...)
+		written = append(written, record)
+		seq, lastHash = record.Seq, record.Hash
+	}
+
+	if len(data) > 0 {
+		if err := l.write(data); err != nil {
+			for _, record := range written {
+				failed, errs = append(failed, record), append(errs, err)
+			}
+		} else {
+			l.seq, l.lastHash = seq, lastHash
+		}
+	}
+	onError := l.onError
+
+	l.mu.Unlock()
+
+	if onError != nil {
+		for i, record := range failed {
+			onError(record, errs[i])
+		}
+	}
+	for _, it := range batch {
+		if it.flushed != nil {
+			close(it.flushed)
+		}
+	}
+}
+
+// seal заполняет Seq, PrevHash и Hash записи и возвращает ее строку журнала.
+func seal(record Record, seq int64, prevHash string) (Record, []byte, error) {
+	record.Seq = seq
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	record.PrevHash = prevHash
+
+	hash, err := hashRecord(record)
+	if err != nil {
+		return Record{}, nil, err
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return Record{}, nil, err
+	}
+	return record, append(line, '\n'), nil
+}
+
+// write дописывает строки в файл и сбрасывает их на диск. Вызывается под l.mu.
+func (l *Log) write(data []byte) error {
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("Не удалось записать в журнал аудита: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("Не удалось записать в журнал аудита: %w", err)
+	}
+	return nil
+}
+
+// Query возвращает записи, подходящие под фильтр, от новых к старым, включая
+// записи из очереди фоновой записи.
+func (l *Log) Query(filter Filter) ([]Record, error) {
+	l.Flush()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := []Record{}
+	err := readRecords(l.path, func(record Record) error {
+		if filter.match(record) {
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+
+	return records, nil
+}
+
+// Close дописывает очередь фоновой записи и закрывает журнал.
+func (l *Log) Close() error {
+	l.queueMu.Lock()
+	if l.closed {
+		l.queueMu.Unlock()
+		return os.ErrClosed
+	}
+	l.closed = true
+	close(l.queue)
+	l.queueMu.Unlock()
+
+	<-l.stopped
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+var ErrChainBroken = errors.New("Цепочка журнала аудита нарушена")
+
+// Verify проверяет всю цепочку хешей и возвращает количество записей.
+func Verify(path string) (int64, error) {
+	var count int64
+	prevHash := ""
+
+	err := readRecords(path, func(record Record) error {
+		count++
+
+		if record.Seq != count {
+			return fmt.Errorf("%w: ожидалась запись %d, найдена %d", ErrChainBroken, count, record.Seq)
+		}
+		if record.PrevHash != prevHash {
+			return fmt.Errorf("%w: запись %d ссылается на другую предыдущую запись", ErrChainBroken, record.Seq)
+		}
+
+		hash, err := hashRecord(record)
+		if err != nil {
+			return err
+		}
+		if hash != record.Hash {
+			return fmt.Errorf("%w: хеш записи %d не совпадает", ErrChainBroken, record.Seq)
+		}
+
+		prevHash = record.Hash
+		return nil
+	})
+
+	return count, err
+}
+
+func hashRecord(record Record) (string, error) {
+	record.Hash = ""
+	body, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(record.PrevHash), body...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func readRecords(path string, fn func(record Record) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%w: строка %d: %v", ErrChainBroken, line, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,119 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"quotes/audit"
+	"strings"
+	"testing"
+)
+
+func TestAppendQueryVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	a, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("Open вернула ошибку: %v", err)
+	}
+
+	records := []audit.Record{
+		{Actor: "alice", Action: "create", QuoteID: 1, After: json.RawMessage(`{"quote":"Quote 1"}`)},
+		{Actor: "bob", Action: "update", QuoteID: 1, Before: json.RawMessage(`{"quote":"Quote 1"}`), After: json.RawMessage(`{"quote":"Quote 2"}`)},
+		{Actor: "alice", Action: "create", QuoteID: 2, After: json.RawMessage(`{"quote":"Quote 3"}`)},
+	}
+	for _, record := range records {
+		if _, err = a.Append(record); err != nil {
+			t.Fatalf("Append вернула ошибку: %v", err)
+		}
+	}
+	a.Close()
+
+	// Тест 1: Цепочка продолжается после повторного открытия
+	a, err = audit.Open(path)
+	if err != nil {
+		t.Fatalf("Open вернула ошибку: %v", err)
+	}
+	last, err := a.Append(audit.Record{Actor: "bob", Action: "delete", QuoteID: 2})
+	if err != nil {
+		t.Fatalf("Append вернула ошибку: %v", err)
+	}
+	if last.Seq != 4 || last.PrevHash == "" {
+		t.Errorf("Ожидалась запись 4 со ссылкой на предыдущую, получено: %+v", last)
+	}
+	defer a.Close()
+
+	// Тест 2: Фильтрация
+	found, err := a.Query(audit.Filter{Actor: "alice"})
+	if err != nil {
+		t.Fatalf("Query вернула ошибку: %v", err)
+	}
+	if len(found) != 2 || found[0].QuoteID != 2 {
+		t.Errorf("Ожидалось 2 записи alice от новых к старым, получено: %+v", found)
+	}
+	found, err = a.Query(audit.Filter{QuoteID: 1, Action: "update"})
+	if err != nil || len(found) != 1 {
+		t.Errorf("Ожидалась 1 запись, получено: %+v, %v", found, err)
+	}
+
+	// Тест 3: Проверка целостной цепочки
+	count, err := audit.Verify(path)
+	if err != nil || count != 4 {
+		t.Fatalf("Ожидалась целостная цепочка из 4 записей, получено: %d, %v", count, err)
+	}
+
+	// Тест 4: Изменение записи обнаруживается
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать журнал: %v", err)
+	}
+	tampered := strings.Replace(string(data), `"actor":"bob"`, `"actor":"eve"`, 1)
+	if err = os.WriteFile(path, []byte(tampered), 0600); err != nil {
+		t.Fatalf("Не удалось записать журнал: %v", err)
+	}
+	if _, err = audit.Verify(path); !errors.Is(err, audit.ErrChainBroken) {
+		t.Errorf("Ожидалась ошибка нарушения цепочки, получено: %v", err)
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	a, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("Open вернула ошибку: %v", err)
+	}
+
+	// Тест 1: Записи из очереди видны в Query в порядке постановки
+	for i := 1; i <= 50; i++ {
+		a.Enqueue(audit.Record{Actor: "alice", Action: "update", QuoteID: i})
+	}
+	found, err := a.Query(audit.Filter{})
+	if err != nil || len(found) != 50 {
+		t.Fatalf("Ожидалось 50 записей, получено: %d, %v", len(found), err)
+	}
+	for i, record := range found {
+		if record.QuoteID != 50-i || record.Seq != int64(50-i) {
+			t.Fatalf("Нарушен порядок записей: %+v", record)
+		}
+	}
+
+	// Тест 2: Close дописывает очередь, цепочка остается целостной
+	for i := 51; i <= 60; i++ {
+		a.Enqueue(audit.Record{Actor: "bob", Action: "delete", QuoteID: i})
+	}
+	if err = a.Close(); err != nil {
+		t.Fatalf("Close вернула ошибку: %v", err)
+	}
+	if count, err := audit.Verify(path); err != nil || count != 60 {
+		t.Errorf("Ожидалась целостная цепочка из 60 записей, получено: %d, %v", count, err)
+	}
+
+	// Тест 3: Запись после закрытия не пишется и не паникует
+	a.Enqueue(audit.Record{Actor: "eve", Action: "delete", QuoteID: 61})
+	a.Flush()
+	if count, _ := audit.Verify(path); count != 60 {
+		t.Errorf("Запись после закрытия попала в журнал, записей: %d", count)
+	}
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+)
+
+const MethodAPIKey = "api_key"
+
+// Principal аутентифицированный клиент.
+type Principal struct {
+	// Subject имя клиента, записывается как автор изменений.
+	Subject string `json:"subject"`
+	// Role роль клиента, определяет его права.
+	Role Role `json:"role"`
+	// Method способ аутентификации.
+	Method string `json:"method"`
+	// KeyID идентификатор API-ключа, если клиент вошел по ключу.
+	KeyID string `json:"key_id,omitempty"`
+	// UserID идентификатор пользователя, если клиент вошел по паролю.
+	UserID int `json:"user_id,omitempty"`
+	// SessionID хеш токена сессии, нужен для выхода.
+	SessionID string `json:"-"`
+}
+
+// ClientID идентификатор клиента для учета лимитов, голосов и владельцев
+// цитат: пользователь, API-ключ или субъект JWT.
+func (principal Principal) ClientID() string {
+	switch {
+	case principal.UserID != 0:
+		return "user:" + strconv.Itoa(principal.UserID)
+	case principal.KeyID != "":
+		return "key:" + principal.KeyID
+	default:
+		return "sub:" + principal.Subject
+	}
+}
+
+type principalKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext возвращает аутентифицированного клиента из контекста.
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// jwk ключ из JWKS (RFC 7517). Поддерживаются симметричные ключи (oct) для
+// HS256, RSA для RS256 и Ed25519 (OKP) для EdDSA.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// verificationKey ключ проверки подписи и алгоритм, для которого он годится.
+type verificationKey struct {
+	kid string
+	alg string
+	key any
+}
+
+func readJWKS(path string) ([]verificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось прочитать JWKS: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("Не удалось разобрать JWKS: %w", err)
+	}
+
+	keys := make([]verificationKey, 0, len(set.Keys))
+	for i, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		parsed, err := key.parse()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS, ключ %d (%s): %w", i, key.Kid, err)
+		}
+		keys = append(keys, parsed)
+	}
+
+	return keys, nil
+}
+
+func (key jwk) parse() (verificationKey, error) {
+	result := verificationKey{kid: key.Kid, alg: key.Alg}
+
+	switch key.Kty {
+	case "oct":
+		secret, err := decodeSegment(key.K)
+		if err != nil || len(secret) == 0 {
+			return result, fmt.Errorf("некорректный симметричный ключ")
+		}
+		result.key = secret
+		result.alg = expectAlg(key.Alg, "HS256")
+	case "RSA":
+		n, errN := decodeSegment(key.N)
+		e, errE := decodeSegment(key.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 {
+			return result, fmt.Errorf("некорректный RSA-ключ")
+		}
+		result.key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		result.alg = expectAlg(key.Alg, "RS256")
+	case "OKP":
+		x, err := decodeSegment(key.X)
+		if key.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return result, fmt.Errorf("поддерживается только Ed25519")
+		}
+		result.key = ed25519.PublicKey(x)
+		result.alg = expectAlg(key.Alg, "EdDSA")
+	default:
+		return result, fmt.Errorf("неподдерживаемый тип ключа %q", key.Kty)
+	}
+
+	if result.alg == "" {
+		return result, fmt.Errorf("алгоритм %q не подходит для ключа %s", key.Alg, key.Kty)
+	}
+	return result, nil
+}
+
+// expectAlg возвращает алгоритм ключа: указанный в JWKS, если он совпадает
+// с единственным поддерживаемым, или пустую строку.
+func expectAlg(alg, supported string) string {
+	if alg == "" || alg == supported {
+		return supported
+	}
+	return ""
+}
+
+func decodeSegment(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(value)
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const MethodJWT = "jwt"
+
+var ErrInvalidToken = errors.New("Недействительный токен")
+
+type JWTOptions struct {
+	// JWKSPath локальный файл JWKS. Файл перечитывается при изменении, поэтому
+	// ключи можно ротировать без перезапуска.
+	JWKSPath string
+	// Issuer и Audience ожидаемые iss и aud, пустое значение не проверяется.
+	Issuer   string
+	Audience string
+	// Leeway допустимое расхождение часов при проверке exp, nbf и iat.
+	Leeway time.Duration
+	// RoleClaim claim с ролью или списком ролей клиента.
+	RoleClaim string
+	// RoleMap сопоставляет значения RoleClaim ролям. Значения, совпадающие с
+	// именами ролей, сопоставляются без настройки.
+	RoleMap map[string]Role
+	// DefaultRole роль клиента, если claim не дал ни одной роли.
+	DefaultRole Role
+}
+
+// JWTVerifier проверяет bearer-токены JWT, подписанные HS256, RS256 или EdDSA.
+type JWTVerifier struct {
+	opts   JWTOptions
+	parser *jwt.Parser
+
+	mu      sync.Mutex
+	keys    []verificationKey
+	modTime time.Time
+	size    int64
+}
+
+func NewJWTVerifier(opts JWTOptions) (*JWTVerifier, error) {
+	if opts.RoleClaim == "" {
+		opts.RoleClaim = "role"
+	}
+	if opts.DefaultRole == "" {
+		opts.DefaultRole = RoleReader
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}),
+		jwt.WithLeeway(opts.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+
+	v := &JWTVerifier{opts: opts, parser: jwt.NewParser(parserOpts...)}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.reloadIfChanged(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// reloadIfChanged перечитывает JWKS, если файл изменился. При ошибке чтения
+// остаются прежние ключи. Вызывается под v.mu.
+func (v *JWTVerifier) reloadIfChanged() error {
+	info, err := os.Stat(v.opts.JWKSPath)
+	if err != nil {
+		return fmt.Errorf("Не удалось прочитать JWKS: %w", err)
+	}
+	if info.ModTime().Equal(v.modTime) && info.Size() == v.size {
+		return nil
+	}
+
+	keys, err := readJWKS(v.opts.JWKSPath)
+	if err != nil {
+		return err
+	}
+
+	v.keys = keys
+	v.modTime, v.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// candidates возвращает ключи, подходящие для токена: с тем же kid или,
+// если kid в токене нет, все ключи алгоритма токена.
+func (v *JWTVerifier) candidates(kid, alg string) []verificationKey {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.reloadIfChanged()
+
+	var keys []verificationKey
+	for _, key := range v.keys {
+		if key.alg == alg && (kid == "" || key.kid == kid) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Verify проверяет подпись и claims токена и возвращает клиента.
+func (v *JWTVerifier) Verify(raw string) (Principal, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := v.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		keys := v.candidates(kid, token.Method.Alg())
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("нет ключа для kid %q и алгоритма %s", kid, token.Method.Alg())
+		}
+
+		set := jwt.VerificationKeySet{}
+		for _, key := range keys {
+			set.Keys = append(set.Keys, key.key)
+		}
+		return set, nil
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Principal{}, fmt.Errorf("%w: отсутствует sub", ErrInvalidToken)
+	}
+
+	return Principal{Subject: subject, Role: v.role(claims), Method: MethodJWT}, nil
+}
+
+// roleRank порядок ролей по возрастанию прав.
+var roleRank = map[Role]int{RoleReader: 0, RoleContributor: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// role выбирает самую сильную роль из значений RoleClaim.
+func (v *JWTVerifier) role(claims jwt.MapClaims) Role {
+	var values []string
+	switch claim := claims[v.opts.RoleClaim].(type) {
+	case string:
+		values = strings.Fields(claim)
+	case []any:
+		for _, item := range claim {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var roles []Role
+	for _, value := range values {
+		if role, ok := v.opts.RoleMap[value]; ok {
+			roles = append(roles, role)
+		} else if role, err := ParseRole(value); err == nil {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return v.opts.DefaultRole
+	}
+
+	sort.Slice(roles, func(i, j int) bool { return roleRank[roles[i]] > roleRank[roles[j]] })
+	return roles[0]
+}
@@ -0,0 +1,140 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"quotes/auth"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatalf("Не удалось сериализовать JWKS: %v", err)
+	}
+	if err = os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Не удалось записать JWKS: %v", err)
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Не удалось подписать токен: %v", err)
+	}
+	return signed
+}
+
+func TestJWTVerifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Не удалось создать RSA-ключ: %v", err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Не удалось создать Ed25519-ключ: %v", err)
+	}
+
+	writeJWKS(t, path,
+		map[string]string{"kty": "oct", "kid": "hs", "k": b64(secret)},
+		map[string]string{"kty": "RSA", "kid": "rs", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		map[string]string{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPublic)},
+	)
+
+	v, err := auth.NewJWTVerifier(auth.JWTOptions{
+		JWKSPath:  path,
+		Issuer:    "https://issuer.example",
+		Audience:  "quotes",
+		Leeway:    time.Minute,
+		RoleClaim: "roles",
+		RoleMap:   map[string]auth.Role{"quotes.write": auth.RoleContributor},
+	})
+	if err != nil {
+		t.Fatalf("NewJWTVerifier вернула ошибку: %v", err)
+	}
+
+	now := time.Now()
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		result := jwt.MapClaims{
+			"sub": "alice",
+			"iss": "https://issuer.example",
+			"aud": "quotes",
+			"iat": now.Unix(),
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for key, value := range overrides {
+			result[key] = value
+		}
+		return result
+	}
+
+	// Тест 1: Все поддерживаемые алгоритмы и сопоставление ролей
+	tests := []struct {
+		token string
+		role  auth.Role
+	}{
+		{sign(t, jwt.SigningMethodHS256, "hs", secret, claims(nil)), auth.RoleReader},
+		{sign(t, jwt.SigningMethodRS256, "rs", rsaKey, claims(jwt.MapClaims{"roles": []string{"quotes.write"}})), auth.RoleContributor},
+		{sign(t, jwt.SigningMethodEdDSA, "ed", edPrivate, claims(jwt.MapClaims{"roles": []string{"quotes.write", "editor"}})), auth.RoleEditor},
+	}
+	for i, tt := range tests {
+		principal, err := v.Verify(tt.token)
+		if err != nil {
+			t.Errorf("Токен %d: Verify вернула ошибку: %v", i, err)
+			continue
+		}
+		if principal.Subject != "alice" || principal.Role != tt.role || principal.Method != auth.MethodJWT {
+			t.Errorf("Токен %d: ожидалась роль %s, получено: %+v", i, tt.role, principal)
+		}
+	}
+
+	// Тест 2: Отклоняются чужие iss и aud, просроченные токены и неверная подпись
+	invalid := []string{
+		sign(t, jwt.SigningMethodHS256, "hs", secret, claims(jwt.MapClaims{"iss": "https://other.example"})),
+		sign(t, jwt.SigningMethodHS256, "hs", secret, claims(jwt.MapClaims{"aud": "billing"})),
+		sign(t, jwt.SigningMethodHS256, "hs", secret, claims(jwt.MapClaims{"exp": now.Add(-2 * time.Minute).Unix()})),
+		sign(t, jwt.SigningMethodHS256, "hs", []byte("wrong secret"), claims(nil)),
+		sign(t, jwt.SigningMethodHS256, "rs", secret, claims(nil)),
+	}
+	for i, token := range invalid {
+		if _, err := v.Verify(token); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("Токен %d должен быть отклонен, получено: %v", i, err)
+		}
+	}
+
+	// Тест 3: Расхождение часов в пределах leeway допускается
+	skewed := sign(t, jwt.SigningMethodHS256, "hs", secret, claims(jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()}))
+	if _, err := v.Verify(skewed); err != nil {
+		t.Errorf("Токен в пределах leeway должен приниматься, получено: %v", err)
+	}
+
+	// Тест 4: Ротация ключей без перезапуска
+	rotated := []byte("fedcba9876543210fedcba9876543210")
+	writeJWKS(t, path, map[string]string{"kty": "oct", "kid": "hs2", "k": b64(rotated)})
+
+	if _, err := v.Verify(sign(t, jwt.SigningMethodHS256, "hs2", rotated, claims(nil))); err != nil {
+		t.Errorf("Токен с новым ключом должен приниматься, получено: %v", err)
+	}
+	if _, err := v.Verify(tests[0].token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("Токен с удаленным ключом должен отклоняться, получено: %v", err)
+	}
+}
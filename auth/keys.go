@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyPrefix начало каждого API-ключа, по нему ключ отличается от других
+// bearer-токенов.
+const KeyPrefix = "qk_"
+
+var (
+	ErrInvalidKey  = errors.New("Недействительный API-ключ")
+	ErrKeyNotFound = errors.New("API-ключ не найден")
+)
+
+// Key сведения об API-ключе. Сам ключ не хранится, только хеш его секретной
+// части, поэтому показать ключ можно лишь один раз при создании.
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Role       Role       `json:"role"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	hash string
+}
+
+// storedKey ключ в файле вместе с хешем секрета.
+type storedKey struct {
+	Key
+	Hash string `json:"hash"`
+}
+
+func (key Key) Active() bool {
+	return key.RevokedAt == nil
+}
+
+// KeyStore хранилище API-ключей в JSON-файле. Изменения, сделанные другим
+// процессом (например, командой quotes keys), подхватываются при следующей
+// проверке ключа.
+type KeyStore struct {
+	mu      sync.Mutex
+	path    string
+	keys    map[string]*Key
+	modTime time.Time
+	size    int64
+	dirty   bool
+}
+
+func OpenKeyStore(path string) (*KeyStore, error) {
+	ks := &KeyStore{path: path, keys: make(map[string]*Key)}
+	if err := ks.load(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// load читает файл ключей, сохраняя более поздние отметки использования из
+// памяти. Вызывается под ks.mu.
+func (ks *KeyStore) load() error {
+	info, err := os.Stat(ks.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(ks.path)
+	if err != nil {
+		return fmt.Errorf("Не удалось прочитать файл ключей: %w", err)
+	}
+
+	var stored []storedKey
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("Не удалось разобрать файл ключей: %w", err)
+		}
+	}
+
+	loaded := make(map[string]*Key, len(stored))
+	for _, item := range stored {
+		key := &item.Key
+		key.hash = item.Hash
+		if key.Role == "" {
+			// Ключи, созданные до появления ролей, получают наименьшие права;
+			// нужную роль дает новый ключ.
+			key.Role = RoleReader
+		}
+		if old, ok := ks.keys[key.ID]; ok && old.LastUsedAt != nil &&
+			(key.LastUsedAt == nil || old.LastUsedAt.After(*key.LastUsedAt)) {
+			key.LastUsedAt = old.LastUsedAt
+		}
+		loaded[key.ID] = key
+	}
+
+	ks.keys = loaded
+	ks.modTime, ks.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// reloadIfChanged перечитывает файл, если он изменился с последнего чтения.
+// Вызывается под ks.mu.
+func (ks *KeyStore) reloadIfChanged() error {
+	info, err := os.Stat(ks.path)
+	if err != nil || info.ModTime().Equal(ks.modTime) && info.Size() == ks.size {
+		return nil
+	}
+	return ks.load()
+}
+
+// save записывает ключи в файл. Вызывается под ks.mu.
+func (ks *KeyStore) save() error {
+	stored := make([]storedKey, 0, len(ks.keys))
+	for _, key := range ks.list() {
+		stored = append(stored, storedKey{Key: key, Hash: key.hash})
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(ks.path, data, 0600); err != nil {
+		return fmt.Errorf("Не удалось записать файл ключей: %w", err)
+	}
+
+	if info, err := os.Stat(ks.path); err == nil {
+		ks.modTime, ks.size = info.ModTime(), info.Size()
+	}
+	ks.dirty = false
+	return nil
+}
+
+// Save сохраняет отметки последнего использования ключей.
+func (ks *KeyStore) Save() error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if !ks.dirty {
+		return nil
+	}
+	if err := ks.reloadIfChanged(); err != nil {
+		return err
+	}
+	return ks.save()
+}
+
+// Create создает ключ с ролью role и возвращает его вместе с открытым
+// значением, которое больше нигде не сохраняется.
+func (ks *KeyStore) Create(name string, role Role) (Key, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Key{}, "", errors.New("Имя ключа не может быть пустым")
+	}
+	if _, err := ParseRole(string(role)); err != nil {
+		return Key{}, "", err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if err := ks.reloadIfChanged(); err != nil {
+		return Key{}, "", err
+	}
+
+	id, err := randomHex(4)
+	for err == nil && ks.keys[id] != nil {
+		id, err = randomHex(4)
+	}
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	key := &Key{ID: id, Name: name, Role: role, CreatedAt: time.Now().UTC(), hash: hashSecret(secret)}
+	ks.keys[id] = key
+
+	if err = ks.save(); err != nil {
+		delete(ks.keys, id)
+		return Key{}, "", err
+	}
+
+	return *key, KeyPrefix + id + "_" + secret, nil
+}
+
+func (ks *KeyStore) List() []Key {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.reloadIfChanged()
+	return ks.list()
+}
+
+// list возвращает копии ключей в порядке создания. Вызывается под ks.mu.
+func (ks *KeyStore) list() []Key {
+	keys := make([]Key, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, *key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+func (ks *KeyStore) Revoke(id string) (Key, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if err := ks.reloadIfChanged(); err != nil {
+		return Key{}, err
+	}
+
+	key, ok := ks.keys[id]
+	if !ok || !key.Active() {
+		return Key{}, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	if err := ks.save(); err != nil {
+		key.RevokedAt = nil
+		return Key{}, err
+	}
+
+	return *key, nil
+}
+
+// Authenticate проверяет открытое значение ключа и отмечает время его
+// использования.
+func (ks *KeyStore) Authenticate(raw string) (Key, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(raw, KeyPrefix), "_")
+	if !strings.HasPrefix(raw, KeyPrefix) || !ok {
+		return Key{}, ErrInvalidKey
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if err := ks.reloadIfChanged(); err != nil {
+		return Key{}, err
+	}
+
+	key, ok := ks.keys[id]
+	if !ok || !key.Active() {
+		return Key{}, ErrInvalidKey
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.hash)) != 1 {
+		return Key{}, ErrInvalidKey
+	}
+
+	now := time.Now().UTC()
+	key.LastUsedAt = &now
+	ks.dirty = true
+
+	return *key, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
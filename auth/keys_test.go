@@ -0,0 +1,80 @@
+package auth_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"quotes/auth"
+	"strings"
+	"testing"
+)
+
+func TestKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	ks, err := auth.OpenKeyStore(path)
+	if err != nil {
+		t.Fatalf("OpenKeyStore вернула ошибку: %v", err)
+	}
+
+	key, token, err := ks.Create("deploy", auth.RoleEditor)
+	if err != nil {
+		t.Fatalf("Create вернула ошибку: %v", err)
+	}
+
+	// Тест 1: В файле хранится только хеш ключа
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать файл ключей: %v", err)
+	}
+	secret := token[strings.LastIndex(token, "_")+1:]
+	if strings.Contains(string(data), secret) || !strings.Contains(string(data), `"hash"`) {
+		t.Errorf("Файл ключей должен содержать хеш, а не ключ: %s", data)
+	}
+
+	// Тест 2: Проверка ключа и отметка использования
+	authenticated, err := ks.Authenticate(token)
+	if err != nil || authenticated.ID != key.ID || authenticated.LastUsedAt == nil {
+		t.Fatalf("Ожидалась успешная проверка ключа, получено: %+v, %v", authenticated, err)
+	}
+	for _, invalid := range []string{"", "qk_" + key.ID + "_wrong", "qk_missing_" + secret, secret} {
+		if _, err = ks.Authenticate(invalid); !errors.Is(err, auth.ErrInvalidKey) {
+			t.Errorf("Ожидалась ошибка для ключа %q, получено: %v", invalid, err)
+		}
+	}
+
+	if err = ks.Save(); err != nil {
+		t.Fatalf("Save вернула ошибку: %v", err)
+	}
+
+	// Тест 3: Отзыв ключа другим процессом подхватывается
+	other, err := auth.OpenKeyStore(path)
+	if err != nil {
+		t.Fatalf("OpenKeyStore вернула ошибку: %v", err)
+	}
+	if keys := other.List(); len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Errorf("Ожидался один ключ с отметкой использования, получено: %+v", keys)
+	}
+	if _, err = other.Revoke(key.ID); err != nil {
+		t.Fatalf("Revoke вернула ошибку: %v", err)
+	}
+	if _, err = other.Revoke(key.ID); !errors.Is(err, auth.ErrKeyNotFound) {
+		t.Errorf("Повторный отзыв должен вернуть ErrKeyNotFound, получено: %v", err)
+	}
+
+	if _, err = ks.Authenticate(token); !errors.Is(err, auth.ErrInvalidKey) {
+		t.Errorf("Отозванный ключ должен отклоняться, получено: %v", err)
+	}
+
+	// Тест 4: Ключ без роли получает наименьшие права
+	legacy := filepath.Join(t.TempDir(), "keys.json")
+	if err = os.WriteFile(legacy, []byte(`[{"id": "old", "name": "deploy", "hash": "00"}]`), 0600); err != nil {
+		t.Fatalf("Не удалось записать файл ключей: %v", err)
+	}
+	if ks, err = auth.OpenKeyStore(legacy); err != nil {
+		t.Fatalf("OpenKeyStore вернула ошибку: %v", err)
+	}
+	if keys := ks.List(); len(keys) != 1 || keys[0].Role != auth.RoleReader {
+		t.Errorf("Ожидалась роль reader, получено: %+v", keys)
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// SessionPrefix начало каждого токена сессии пользователя.
+const SessionPrefix = "qs_"
+
+const MethodSession = "session"
+
+// Параметры argon2id по рекомендации OWASP: 19 МиБ памяти, 2 прохода.
+const (
+	argonMemory  = 19 * 1024
+	argonTime    = 2
+	argonThreads = 1
+	argonSaltLen = 16
+	argonKeyLen  = 32
+)
+
+var ErrInvalidPasswordHash = errors.New("Некорректный хеш пароля")
+
+// HashPassword возвращает хеш пароля argon2id в формате PHC:
+// $argon2id$v=19$m=19456,t=2,p=1$<соль>$<хеш>.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword сверяет пароль с хешем HashPassword. Параметры берутся из
+// самого хеша, поэтому их можно менять, не сбрасывая старые пароли.
+func CheckPassword(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, ErrInvalidPasswordHash
+	}
+
+	var version int
+	var memory, passes uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrInvalidPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &passes, &threads); err != nil {
+		return false, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrInvalidPasswordHash
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(expected) == 0 {
+		return false, ErrInvalidPasswordHash
+	}
+
+	key := argon2.IDKey([]byte(password), salt, passes, memory, threads, uint32(len(expected)))
+	return subtle.ConstantTimeCompare(key, expected) == 1, nil
+}
+
+// NewSessionToken создает токен сессии. Хранить нужно только хеш, токен
+// отдается пользователю один раз при входе.
+func NewSessionToken() (token, hash string, err error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	return SessionPrefix + secret, hashSecret(secret), nil
+}
+
+// SessionHash возвращает хеш токена сессии для поиска в хранилище.
+func SessionHash(token string) (string, bool) {
+	secret, ok := strings.CutPrefix(token, SessionPrefix)
+	if !ok || secret == "" {
+		return "", false
+	}
+	return hashSecret(secret), true
+}
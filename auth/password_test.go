@@ -0,0 +1,49 @@
+package auth_test
+
+import (
+	"quotes/auth"
+	"strings"
+	"testing"
+)
+
+func TestPassword(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword вернула ошибку: %v", err)
+	}
+
+	// Тест 1: Хеш в формате PHC не содержит пароля
+	if !strings.HasPrefix(hash, "$argon2id$v=19$") || strings.Contains(hash, "correct horse") {
+		t.Errorf("Неожиданный хеш: %s", hash)
+	}
+
+	// Тест 2: Верный пароль подходит, неверный нет
+	if ok, err := auth.CheckPassword(hash, "correct horse"); !ok || err != nil {
+		t.Errorf("Ожидалось совпадение пароля, получено: %v, %v", ok, err)
+	}
+	if ok, err := auth.CheckPassword(hash, "wrong horse"); ok || err != nil {
+		t.Errorf("Неверный пароль не должен подходить, получено: %v, %v", ok, err)
+	}
+
+	// Тест 3: Одинаковые пароли дают разные хеши
+	if other, _ := auth.HashPassword("correct horse"); other == hash {
+		t.Error("Хеши одинаковых паролей должны различаться солью")
+	}
+
+	// Тест 4: Некорректный хеш
+	if _, err := auth.CheckPassword("$2a$10$bcrypt", "correct horse"); err == nil {
+		t.Error("Ожидалась ошибка для некорректного хеша")
+	}
+
+	// Тест 5: Токен сессии хешируется так же при проверке
+	token, hash, err := auth.NewSessionToken()
+	if err != nil {
+		t.Fatalf("NewSessionToken вернула ошибку: %v", err)
+	}
+	if got, ok := auth.SessionHash(token); !ok || got != hash || strings.Contains(hash, token[len(auth.SessionPrefix):]) {
+		t.Errorf("Хеш токена не совпадает: %s, %s", got, hash)
+	}
+	if _, ok := auth.SessionHash("qk_key"); ok {
+		t.Error("API-ключ не должен считаться токеном сессии")
+	}
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type Role string
+
+const (
+	RoleReader      Role = "reader"
+	RoleContributor Role = "contributor"
+	RoleEditor      Role = "editor"
+	RoleAdmin       Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermRead   Permission = "quotes:read"
+	PermCreate Permission = "quotes:create"
+	// PermUpdateOwn разрешает изменять только цитаты, добавленные самим клиентом.
+	PermUpdateOwn Permission = "quotes:update:own"
+	PermUpdate    Permission = "quotes:update"
+	PermDelete    Permission = "quotes:delete"
+	// PermRestore разрешает просмотр корзины, восстановление и откат цитат.
+	PermRestore Permission = "quotes:restore"
+	// PermModerate разрешает одобрять и отклонять цитаты из очереди
+	// модерации; цитаты клиентов с этим правом публикуются без модерации.
+	PermModerate Permission = "quotes:moderate"
+	PermAdmin    Permission = "admin"
+)
+
+// permissions матрица прав ролей.
+var permissions = map[Role][]Permission{
+	RoleReader:      {PermRead},
+	RoleContributor: {PermRead, PermCreate, PermUpdateOwn},
+	RoleEditor:      {PermRead, PermCreate, PermUpdateOwn, PermUpdate, PermDelete, PermRestore, PermModerate},
+	RoleAdmin:       {PermRead, PermCreate, PermUpdateOwn, PermUpdate, PermDelete, PermRestore, PermModerate, PermAdmin},
+}
+
+func ParseRole(value string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := permissions[role]; !ok {
+		return "", fmt.Errorf("Неизвестная роль %q, ожидается reader, contributor, editor или admin", value)
+	}
+	return role, nil
+}
+
+func (role Role) Can(permission Permission) bool {
+	for _, granted := range permissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ForbiddenError отказ в доступе с причиной, которая возвращается клиенту.
+type ForbiddenError struct {
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string {
+	return "Доступ запрещен: " + e.Reason
+}
+
+func Forbidden(format string, args ...any) error {
+	return &ForbiddenError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// RoleOf возвращает роль клиента из контекста. Анонимный клиент - читатель.
+func RoleOf(ctx context.Context) Role {
+	if principal, ok := FromContext(ctx); ok {
+		return principal.Role
+	}
+	return RoleReader
+}
+
+// Authorize проверяет, что у клиента есть хотя бы одно из прав.
+func Authorize(ctx context.Context, required ...Permission) error {
+	role := RoleOf(ctx)
+	for _, permission := range required {
+		if role.Can(permission) {
+			return nil
+		}
+	}
+	return Forbidden("роль %s не дает права %s", role, required[0])
+}
+
+// AuthorizeOwner проверяет право изменить цитату владельца owner: с
+// PermUpdate можно менять любые цитаты, с PermUpdateOwn - только свои.
+// Владелец сравнивается по Principal.ClientID, а не по имени, которое у
+// ключа, субъекта JWT и пользователя может совпадать.
+func AuthorizeOwner(ctx context.Context, owner string) error {
+	role := RoleOf(ctx)
+	if role.Can(PermUpdate) {
+		return nil
+	}
+
+	principal, ok := FromContext(ctx)
+	if ok && role.Can(PermUpdateOwn) && owner != "" && owner == principal.ClientID() {
+		return nil
+	}
+	if role.Can(PermUpdateOwn) {
+		return Forbidden("роль %s позволяет изменять только свои цитаты", role)
+	}
+	return Forbidden("роль %s не дает права %s", role, PermUpdate)
+}
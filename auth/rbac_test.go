@@ -0,0 +1,60 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"quotes/auth"
+	"testing"
+)
+
+func TestRoles(t *testing.T) {
+	// Тест 1: Матрица прав
+	tests := []struct {
+		role       auth.Role
+		permission auth.Permission
+		allowed    bool
+	}{
+		{auth.RoleReader, auth.PermRead, true},
+		{auth.RoleReader, auth.PermCreate, false},
+		{auth.RoleContributor, auth.PermCreate, true},
+		{auth.RoleContributor, auth.PermUpdate, false},
+		{auth.RoleContributor, auth.PermDelete, false},
+		{auth.RoleEditor, auth.PermDelete, true},
+		{auth.RoleContributor, auth.PermModerate, false},
+		{auth.RoleEditor, auth.PermModerate, true},
+		{auth.RoleEditor, auth.PermAdmin, false},
+		{auth.RoleAdmin, auth.PermAdmin, true},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Can(tt.permission); got != tt.allowed {
+			t.Errorf("%s.Can(%s): ожидалось %v, получено %v", tt.role, tt.permission, tt.allowed, got)
+		}
+	}
+
+	if _, err := auth.ParseRole("owner"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестной роли")
+	}
+
+	// Тест 2: Анонимный клиент - читатель
+	var forbidden *auth.ForbiddenError
+	if err := auth.Authorize(context.Background(), auth.PermCreate); !errors.As(err, &forbidden) {
+		t.Errorf("Ожидался отказ анонимному клиенту, получено: %v", err)
+	}
+
+	// Тест 3: Участник изменяет только свои цитаты
+	alice := auth.ContextWithPrincipal(context.Background(), auth.Principal{Subject: "alice", Role: auth.RoleContributor, KeyID: "k1"})
+	if err := auth.AuthorizeOwner(alice, "key:k1"); err != nil {
+		t.Errorf("Участник должен изменять свою цитату, получено: %v", err)
+	}
+	// Совпадение имени с субъектом JWT или другим ключом не дает прав
+	for _, submitter := range []string{"alice", "sub:alice", "key:k2", ""} {
+		if err := auth.AuthorizeOwner(alice, submitter); !errors.As(err, &forbidden) {
+			t.Errorf("Участник не должен изменять цитату %q, получено: %v", submitter, err)
+		}
+	}
+
+	editor := auth.ContextWithPrincipal(context.Background(), auth.Principal{Subject: "carol", Role: auth.RoleEditor})
+	if err := auth.AuthorizeOwner(editor, "bob"); err != nil {
+		t.Errorf("Редактор должен изменять любые цитаты, получено: %v", err)
+	}
+}
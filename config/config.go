@@ -0,0 +1,725 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"quotes/auth"
+	"quotes/logger"
+	"quotes/ratelimit"
+	"quotes/tracing"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config собирается из слоев в порядке возрастания приоритета: значения по
+// умолчанию, файл конфигурации, .env, переменные окружения, флаги.
+type Config struct {
+	JSONPath        string
+	Port            int
+	TrashRetention  time.Duration
+	SaveInterval    time.Duration
+	ShutdownTimeout time.Duration
+	Interactive     bool
+	Moderation      bool
+	ReportThreshold int
+	FilterPath      string
+	LogLevel        slog.Level
+	LogFormat       string
+	LogOutputs      []string
+	LogMaxSizeMB    int
+	LogMaxAge       time.Duration
+	LogMaxBackups   int
+	LogCompress     bool
+	AuditPath       string
+	APIKeysPath     string
+	SessionTTL      time.Duration
+	JWKSPath        string
+	JWTIssuer       string
+	JWTAudience     string
+	JWTLeeway       time.Duration
+	JWTRoleClaim    string
+	JWTRoleMap      map[string]auth.Role
+	JWTDefaultRole  auth.Role
+	TraceExporter   string
+	TracePath       string
+	TraceSampling   float64
+	RateReadPerSec  float64
+	RateReadBurst   int
+	RateWritePerSec float64
+	RateWriteBurst  int
+	QuotaReadDaily  int
+	QuotaWriteDaily int
+}
+
+// ErrPrintConfig возвращается Load, если запрошен вывод конфигурации.
+var ErrPrintConfig = errors.New("запрошен вывод конфигурации")
+
+type setting struct {
+	env    string
+	file   string
+	flag   string
+	usage  string
+	isBool bool
+	// live означает, что параметр применяется без перезапуска.
+	live bool
+	set  func(c *Config, value string) error
+	get  func(c *Config) string
+}
+
+var settings = []setting{
+	{
+		env: "JSONPATH", file: "json_path", flag: "json-path",
+		usage: "путь к файлу с цитатами",
+		set:   func(c *Config, value string) error { c.JSONPath = value; return nil },
+		get:   func(c *Config) string { return c.JSONPath },
+	},
+	{
+		env: "PORT", file: "port", flag: "port",
+		usage: "порт HTTP-сервера",
+		set:   func(c *Config, value string) error { return setInt(&c.Port, value) },
+		get:   func(c *Config) string { return strconv.Itoa(c.Port) },
+	},
+	{
+		env: "TRASH_RETENTION", file: "trash_retention", flag: "trash-retention",
+		usage: "срок хранения цитат в корзине",
+		live:  true,
+		set:   func(c *Config, value string) error { return setDuration(&c.TrashRetention, value) },
+		get:   func(c *Config) string { return c.TrashRetention.String() },
+	},
+	{
+		env: "SAVE_INTERVAL", file: "save_interval", flag: "save-interval",
+		usage: "интервал сохранения изменений на диск, 0 - только при остановке",
+		live:  true,
+		set:   func(c *Config, value string) error { return setDuration(&c.SaveInterval, value) },
+		get:   func(c *Config) string { return c.SaveInterval.String() },
+	},
+	{
+		env: "SHUTDOWN_TIMEOUT", file: "shutdown_timeout", flag: "shutdown-timeout",
+		usage: "время ожидания завершения запросов при остановке",
+		live:  true,
+		set:   func(c *Config, value string) error { return setDuration(&c.ShutdownTimeout, value) },
+		get:   func(c *Config) string { return c.ShutdownTimeout.String() },
+	},
+	{
+		env: "INTERACTIVE", file: "interactive", flag: "interactive",
+		usage:  "завершать работу по нажатию Enter",
+		isBool: true,
+		set:    func(c *Config, value string) error { return setBool(&c.Interactive, value) },
+		get:    func(c *Config) string { return strconv.FormatBool(c.Interactive) },
+	},
+	{
+		env: "MODERATION", file: "moderation", flag: "moderation",
+		usage:  "отправлять цитаты клиентов без права модерации в очередь модерации",
+		isBool: true,
+		live:   true,
+		set:    func(c *Config, value string) error { return setBool(&c.Moderation, value) },
+		get:    func(c *Config) string { return strconv.FormatBool(c.Moderation) },
+	},
+	{
+		env: "REPORT_THRESHOLD", file: "report_threshold", flag: "report-threshold",
+		usage: "число открытых жалоб, после которого цитата скрывается до решения администратора, 0 - не скрывать",
+		live:  true,
+		set:   func(c *Config, value string) error { return setInt(&c.ReportThreshold, value) },
+		get:   func(c *Config) string { return strconv.Itoa(c.ReportThreshold) },
+	},
+	{
+		env: "FILTER_PATH", file: "filter_path", flag: "filter-path",
+		usage: "файл правил фильтра содержимого, пусто - без фильтра",
+		live:  true,
+		set:   func(c *Config, value string) error { c.FilterPath = value; return nil },
+		get:   func(c *Config) string { return c.FilterPath },
+	},
+	{
+		env: "LOG_LEVEL", file: "log_level", flag: "log-level",
+		usage: "минимальный уровень логирования: debug, info, warn, error",
+		live:  true,
+		set:   func(c *Config, value string) error { return setLevel(&c.LogLevel, value) },
+		get:   func(c *Config) string { return strings.ToLower(c.LogLevel.String()) },
+	},
+	{
+		env: "LOG_FORMAT", file: "log_format", flag: "log-format",
+		usage: "формат логов: text или json",
+		set: func(c *Config, value string) error {
+			c.LogFormat = strings.ToLower(strings.TrimSpace(value))
+			return nil
+		},
+		get: func(c *Config) string { return c.LogFormat },
+	},
+	{
+		env: "LOG_OUTPUT", file: "log_output", flag: "log-output",
+		usage: "приемники логов через запятую: stdout, stderr или пути к файлам",
+		set:   func(c *Config, value string) error { c.LogOutputs = splitList(value); return nil },
+		get:   func(c *Config) string { return strings.Join(c.LogOutputs, ",") },
+	},
+	{
+		env: "LOG_MAX_SIZE_MB", file: "log_max_size_mb", flag: "log-max-size-mb",
+		usage: "размер файла логов в мегабайтах для ротации, 0 - без ротации по размеру",
+		set:   func(c *Config, value string) error { return setInt(&c.LogMaxSizeMB, value) },
+		get:   func(c *Config) string { return strconv.Itoa(c.LogMaxSizeMB) },
+	},
+	{
+		env: "LOG_MAX_AGE", file: "log_max_age", flag: "log-max-age",
+		usage: "возраст файла логов для ротации, 0 - без ротации по возрасту",
+		set:   func(c *Config, value string) error { return setDuration(&c.LogMaxAge, value) },
+		get:   func(c *Config) string { return c.LogMaxAge.String() },
+	},
+	{
+		env: "LOG_MAX_BACKUPS", file: "log_max_backups", flag: "log-max-backups",
+		usage: "сколько ротированных файлов логов хранить, 0 - все",
+		set:   func(c *Config, value string) error { return setInt(&c.LogMaxBackups, value) },
+		get:   func(c *Config) string { return strconv.Itoa(c.LogMaxBackups) },
+	},
+	{
+		env: "LOG_COMPRESS", file: "log_compress", flag: "log-compress",
+		usage:  "сжимать ротированные файлы логов gzip",
+		isBool: true,
+		set:    func(c *Config, value string) error { return setBool(&c.LogCompress, value) },
+		get:    func(c *Config) string { return strconv.FormatBool(c.LogCompress) },
+	},
+	{
+		env: "AUDIT_PATH", file: "audit_path", flag: "audit-path",
+		usage: "путь к журналу аудита изменений цитат",
+		set:   func(c *Config, value string) error { c.AuditPath = value; return nil },
+		get:   func(c *Config) string { return c.AuditPath },
+	},
+	{
+		env: "API_KEYS_PATH", file: "api_keys_path", flag: "api-keys-path",
+		usage: "путь к файлу API-ключей",
+		set:   func(c *Config, value string) error { c.APIKeysPath = value; return nil },
+		get:   func(c *Config) string { return c.APIKeysPath },
+	},
+	{
+		env: "SESSION_TTL", file: "session_ttl", flag: "session-ttl",
+		usage: "срок действия сессии пользователя",
+		set:   func(c *Config, value string) error { return setDuration(&c.SessionTTL, value) },
+		get:   func(c *Config) string { return c.SessionTTL.String() },
+	},
+	{
+		env: "JWT_JWKS_PATH", file: "jwt_jwks_path", flag: "jwt-jwks-path",
+		usage: "локальный файл JWKS для проверки JWT, пусто - JWT не принимаются",
+		set:   func(c *Config, value string) error { c.JWKSPath = strings.TrimSpace(value); return nil },
+		get:   func(c *Config) string { return c.JWKSPath },
+	},
+	{
+		env: "JWT_ISSUER", file: "jwt_issuer", flag: "jwt-issuer",
+		usage: "ожидаемый iss токенов, пусто - не проверяется",
+		set:   func(c *Config, value string) error { c.JWTIssuer = strings.TrimSpace(value); return nil },
+		get:   func(c *Config) string { return c.JWTIssuer },
+	},
+	{
+		env: "JWT_AUDIENCE", file: "jwt_audience", flag: "jwt-audience",
+		usage: "ожидаемый aud токенов, пусто - не проверяется",
+		set:   func(c *Config, value string) error { c.JWTAudience = strings.TrimSpace(value); return nil },
+		get:   func(c *Config) string { return c.JWTAudience },
+	},
+	{
+		env: "JWT_LEEWAY", file: "jwt_leeway", flag: "jwt-leeway",
+		usage: "допустимое расхождение часов при проверке exp, nbf и iat",
+		set:   func(c *Config, value string) error { return setDuration(&c.JWTLeeway, value) },
+		get:   func(c *Config) string { return c.JWTLeeway.String() },
+	},
+	{
+		env: "JWT_ROLE_CLAIM", file: "jwt_role_claim", flag: "jwt-role-claim",
+		usage: "claim токена с ролью или списком ролей",
+		set:   func(c *Config, value string) error { c.JWTRoleClaim = strings.TrimSpace(value); return nil },
+		get:   func(c *Config) string { return c.JWTRoleClaim },
+	},
+	{
+		env: "JWT_ROLE_MAP", file: "jwt_role_map", flag: "jwt-role-map",
+		usage: "сопоставление значений claim ролям: значение=роль через запятую",
+		set:   func(c *Config, value string) error { return setRoleMap(&c.JWTRoleMap, value) },
+		get: func(c *Config) string {
+			items := make([]string, 0, len(c.JWTRoleMap))
+			for value, role := range c.JWTRoleMap {
+				items = append(items, value+"="+string(role))
+			}
+			sort.Strings(items)
+			return strings.Join(items, ",")
+		},
+	},
+	{
+		env: "JWT_DEFAULT_ROLE", file: "jwt_default_role", flag: "jwt-default-role",
+		usage: "роль клиента с JWT без подходящего claim",
+		set:   func(c *Config, value string) error { return setRole(&c.JWTDefaultRole, value) },
+		get:   func(c *Config) string { return string(c.JWTDefaultRole) },
+	},
+	{
+		env: "TRACE_EXPORTER", file: "trace_exporter", flag: "trace-exporter",
+		usage: "экспортер трассировки: none, stdout или otlp-file",
+		set: func(c *Config, value string) error {
+			c.TraceExporter = strings.ToLower(strings.TrimSpace(value))
+			return nil
+		},
+		get: func(c *Config) string { return c.TraceExporter },
+	},
+	{
+		env: "TRACE_PATH", file: "trace_path", flag: "trace-path",
+		usage: "файл трассировки для экспортера otlp-file",
+		set:   func(c *Config, value string) error { c.TracePath = value; return nil },
+		get:   func(c *Config) string { return c.TracePath },
+	},
+	{
+		env: "TRACE_SAMPLING", file: "trace_sampling", flag: "trace-sampling",
+		usage: "доля записываемых трасс от 0 до 1",
+		set:   func(c *Config, value string) error { return setFloat(&c.TraceSampling, value) },
+		get:   func(c *Config) string { return strconv.FormatFloat(c.TraceSampling, 'g', -1, 64) },
+	},
+	{
+		env: "RATE_READ_PER_SEC", file: "rate_read_per_sec", flag: "rate-read-per-sec",
+		usage: "запросов на чтение в секунду от одного клиента, 0 - без ограничения",
+		live:  true,
+		set:   func(c *Config, value string) error { return setFloat(&c.RateReadPerSec, value) },
+		get:   func(c *Config) string { return strconv.FormatFloat(c.RateReadPerSec, 'g', -1, 64) },
+	},
+	{
+		env: "RATE_READ_BURST", file: "rate_read_burst", flag: "rate-read-burst",
+		usage: "запросов на чтение подряд без паузы",
+		live:  true,
+		set:   func(c *Config, value string) error { return setInt(&c.RateReadBurst, value) },
+		get:   func(c *Config) string { return strconv.Itoa(c.RateReadBurst) },
+	},
+	{
+		env: "RATE_WRITE_PER_SEC", file: "rate_write_per_sec", flag: "rate-write-per-sec",
+		usage: "запросов на изменение в секунду от одного клиента, 0 - без ограничения",
+		live:  true,
+		set:   func(c *Config, value string) error { return setFloat(&c.RateWritePerSec, value) },
+		get:   func(c *Config) string { return strconv.FormatFloat(c.RateWritePerSec, 'g', -1, 64) },
+	},
+	{
+		env: "RATE_WRITE_BURST", file: "rate_write_burst", flag: "rate-write-burst",
+		usage: "запросов на изменение подряд без паузы",
+		live:  true,
+		set:   func(c *Config, value string) error { return setInt(&c.RateWriteBurst, value) },
+		get:   func(c *Config) string { return strconv.Itoa(c.RateWriteBurst) },
+	},
+	{
+		env: "QUOTA_READ_DAILY", file: "quota_read_daily", flag: "quota-read-daily",
+		usage: "запросов на чтение от одного клиента за сутки, 0 - без квоты",
+		live:  true,
+		set:   func(c *Config, value string) error { return setInt(&c.QuotaReadDaily, value) },
+		get:   func(c *Config) string { return strconv.Itoa(c.QuotaReadDaily) },
+	},
+	{
+		env: "QUOTA_WRITE_DAILY", file: "quota_write_daily", flag: "quota-write-daily",
+		usage: "запросов на изменение от одного клиента за сутки, 0 - без квоты",
+		live:  true,
+		set:   func(c *Config, value string) error { return setInt(&c.QuotaWriteDaily, value) },
+		get:   func(c *Config) string { return strconv.Itoa(c.QuotaWriteDaily) },
+	},
+}
+
+func Default() *Config {
+	return &Config{
+		JSONPath:        "./storage/quotes.json",
+		Port:            8080,
+		TrashRetention:  30 * 24 * time.Hour,
+		SaveInterval:    30 * time.Second,
+		ShutdownTimeout: 10 * time.Second,
+		ReportThreshold: 3,
+		LogLevel:        slog.LevelInfo,
+		LogFormat:       logger.FormatText,
+		LogOutputs:      []string{logger.DefaultOutput},
+		LogMaxBackups:   7,
+		AuditPath:       "./storage/audit.log",
+		APIKeysPath:     "./storage/api_keys.json",
+		SessionTTL:      7 * 24 * time.Hour,
+		JWTLeeway:       30 * time.Second,
+		JWTRoleClaim:    "role",
+		JWTDefaultRole:  auth.RoleReader,
+		TraceExporter:   tracing.ExporterNone,
+		TracePath:       "./storage/traces.jsonl",
+		TraceSampling:   1,
+		RateReadPerSec:  20,
+		RateReadBurst:   40,
+		RateWritePerSec: 2,
+		RateWriteBurst:  5,
+		QuotaWriteDaily: 1000,
+	}
+}
+
+// Load собирает конфигурацию из всех слоев. Путь к файлу конфигурации
+// задается флагом -config или переменной QUOTES_CONFIG.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("quotes", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("QUOTES_CONFIG"), "путь к файлу конфигурации (YAML или TOML)")
+	envPath := fs.String("env", ".env", "путь к .env файлу")
+	printConfig := fs.Bool("print-config", false, "вывести итоговую конфигурацию и выйти")
+
+	flags := make([]*flagValue, len(settings))
+	for i := range settings {
+		flags[i] = &flagValue{setting: &settings[i]}
+		fs.Var(flags[i], settings[i].flag, settings[i].usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	c := Default()
+
+	if *configPath != "" {
+		if err := c.loadFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	dotEnv, err := ReadDotEnv(*envPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err = c.apply(func(s *setting) (string, bool) {
+		value, ok := dotEnv[s.env]
+		return value, ok
+	}, ".env"); err != nil {
+		return nil, err
+	}
+
+	if err = c.apply(func(s *setting) (string, bool) {
+		return os.LookupEnv(s.env)
+	}, "окружение"); err != nil {
+		return nil, err
+	}
+
+	for i, value := range flags {
+		if value.isSet {
+			if err = settings[i].set(c, value.value); err != nil {
+				return nil, fmt.Errorf("флаг -%s: %w", settings[i].flag, err)
+			}
+		}
+	}
+
+	if err = c.Validate(); err != nil {
+		return nil, err
+	}
+
+	if *printConfig {
+		return c, ErrPrintConfig
+	}
+
+	return c, nil
+}
+
+func (c *Config) apply(lookup func(s *setting) (string, bool), source string) error {
+	for i := range settings {
+		value, ok := lookup(&settings[i])
+		if !ok {
+			continue
+		}
+		if err := settings[i].set(c, value); err != nil {
+			return fmt.Errorf("%s, %s: %w", source, settings[i].env, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Не удалось прочитать файл конфигурации: %w", err)
+	}
+
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("Неизвестный формат файла конфигурации: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("Не удалось разобрать файл конфигурации: %w", err)
+	}
+
+	for key := range values {
+		if findByFile(key) == nil {
+			return fmt.Errorf("%s: неизвестный параметр %q", path, key)
+		}
+	}
+
+	return c.apply(func(s *setting) (string, bool) {
+		value, ok := values[s.file]
+		if !ok {
+			return "", false
+		}
+		return fileValue(value), true
+	}, path)
+}
+
+// fileValue приводит значение из файла конфигурации к строке в формате
+// переменных окружения: массивы записываются через запятую.
+func fileValue(value any) string {
+	items, ok := value.([]any)
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	list := make([]string, len(items))
+	for i, item := range items {
+		list[i] = fmt.Sprint(item)
+	}
+	return strings.Join(list, ",")
+}
+
+func findByFile(key string) *setting {
+	for i := range settings {
+		if settings[i].file == key {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if strings.TrimSpace(c.JSONPath) == "" {
+		errs = append(errs, errors.New("JSONPATH не может быть пустым"))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT вне диапазона 1-65535: %d", c.Port))
+	}
+	if c.TrashRetention <= 0 {
+		errs = append(errs, fmt.Errorf("TRASH_RETENTION должен быть положительным: %s", c.TrashRetention))
+	}
+	if c.SaveInterval < 0 {
+		errs = append(errs, fmt.Errorf("SAVE_INTERVAL не может быть отрицательным: %s", c.SaveInterval))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("SHUTDOWN_TIMEOUT должен быть положительным: %s", c.ShutdownTimeout))
+	}
+	if c.ReportThreshold < 0 {
+		errs = append(errs, fmt.Errorf("REPORT_THRESHOLD не может быть отрицательным: %d", c.ReportThreshold))
+	}
+
+	if c.LogFormat != logger.FormatText && c.LogFormat != logger.FormatJSON {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT должен быть text или json: %s", c.LogFormat))
+	}
+
+	if strings.TrimSpace(c.AuditPath) == "" {
+		errs = append(errs, errors.New("AUDIT_PATH не может быть пустым"))
+	}
+	if strings.TrimSpace(c.APIKeysPath) == "" {
+		errs = append(errs, errors.New("API_KEYS_PATH не может быть пустым"))
+	}
+	if c.SessionTTL <= 0 {
+		errs = append(errs, fmt.Errorf("SESSION_TTL должен быть положительным: %s", c.SessionTTL))
+	}
+	if c.JWTLeeway < 0 {
+		errs = append(errs, fmt.Errorf("JWT_LEEWAY не может быть отрицательным: %s", c.JWTLeeway))
+	}
+	if c.JWKSPath != "" && c.JWTRoleClaim == "" {
+		errs = append(errs, errors.New("JWT_ROLE_CLAIM не может быть пустым"))
+	}
+	if len(c.LogOutputs) == 0 {
+		errs = append(errs, errors.New("LOG_OUTPUT не может быть пустым"))
+	}
+	if c.LogMaxSizeMB < 0 || c.LogMaxBackups < 0 || c.LogMaxAge < 0 {
+		errs = append(errs, errors.New("LOG_MAX_SIZE_MB, LOG_MAX_AGE и LOG_MAX_BACKUPS не могут быть отрицательными"))
+	}
+
+	switch c.TraceExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLPFile:
+		if strings.TrimSpace(c.TracePath) == "" {
+			errs = append(errs, errors.New("TRACE_PATH не может быть пустым для экспортера otlp-file"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("TRACE_EXPORTER должен быть none, stdout или otlp-file: %s", c.TraceExporter))
+	}
+	if c.TraceSampling < 0 || c.TraceSampling > 1 {
+		errs = append(errs, fmt.Errorf("TRACE_SAMPLING должен быть от 0 до 1: %g", c.TraceSampling))
+	}
+
+	if c.RateReadPerSec < 0 || c.RateWritePerSec < 0 {
+		errs = append(errs, errors.New("RATE_READ_PER_SEC и RATE_WRITE_PER_SEC не могут быть отрицательными"))
+	}
+	if c.RateReadPerSec > 0 && c.RateReadBurst < 1 {
+		errs = append(errs, fmt.Errorf("RATE_READ_BURST должен быть положительным: %d", c.RateReadBurst))
+	}
+	if c.RateWritePerSec > 0 && c.RateWriteBurst < 1 {
+		errs = append(errs, fmt.Errorf("RATE_WRITE_BURST должен быть положительным: %d", c.RateWriteBurst))
+	}
+	if c.QuotaReadDaily < 0 || c.QuotaWriteDaily < 0 {
+		errs = append(errs, errors.New("QUOTA_READ_DAILY и QUOTA_WRITE_DAILY не могут быть отрицательными"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("Некорректная конфигурация: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Print выводит итоговую конфигурацию в формате .env.
+func (c *Config) Print(w io.Writer) {
+	for i := range settings {
+		fmt.Fprintf(w, "%s=%s\n", settings[i].env, settings[i].get(c))
+	}
+}
+
+// Values возвращает итоговую конфигурацию в виде переменная -> значение.
+func (c *Config) Values() map[string]string {
+	values := make(map[string]string, len(settings))
+	for i := range settings {
+		values[settings[i].env] = settings[i].get(c)
+	}
+	return values
+}
+
+type flagValue struct {
+	setting *setting
+	value   string
+	isSet   bool
+}
+
+func (f *flagValue) String() string {
+	return f.value
+}
+
+func (f *flagValue) Set(value string) error {
+	f.value = value
+	f.isSet = true
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.setting != nil && f.setting.isBool
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// LoggerOptions переводит настройки логирования в параметры logger.New.
+func (c *Config) LoggerOptions() logger.Options {
+	return logger.Options{
+		Level:   c.LogLevel,
+		Format:  c.LogFormat,
+		Outputs: c.LogOutputs,
+		Rotate: logger.RotateOptions{
+			MaxSize:    int64(c.LogMaxSizeMB) << 20,
+			MaxAge:     c.LogMaxAge,
+			MaxBackups: c.LogMaxBackups,
+			Compress:   c.LogCompress,
+		},
+	}
+}
+
+// TracingOptions переводит настройки трассировки в параметры tracing.Setup.
+func (c *Config) TracingOptions(version string) tracing.Options {
+	return tracing.Options{
+		Exporter:    c.TraceExporter,
+		Path:        c.TracePath,
+		SampleRatio: c.TraceSampling,
+		Version:     version,
+	}
+}
+
+// JWTOptions переводит настройки JWT в параметры auth.NewJWTVerifier.
+func (c *Config) JWTOptions() auth.JWTOptions {
+	return auth.JWTOptions{
+		JWKSPath:    c.JWKSPath,
+		Issuer:      c.JWTIssuer,
+		Audience:    c.JWTAudience,
+		Leeway:      c.JWTLeeway,
+		RoleClaim:   c.JWTRoleClaim,
+		RoleMap:     c.JWTRoleMap,
+		DefaultRole: c.JWTDefaultRole,
+	}
+}
+
+// RateLimits переводит настройки ограничения запросов в параметры
+// ratelimit.Limiter.
+func (c *Config) RateLimits() ratelimit.Limits {
+	return ratelimit.Limits{
+		Read:  ratelimit.Limit{Rate: c.RateReadPerSec, Burst: c.RateReadBurst, Daily: c.QuotaReadDaily},
+		Write: ratelimit.Limit{Rate: c.RateWritePerSec, Burst: c.RateWriteBurst, Daily: c.QuotaWriteDaily},
+	}
+}
+
+func setInt(target *int, value string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("ожидалось целое число: %q", value)
+	}
+	*target = n
+	return nil
+}
+
+func setFloat(target *float64, value string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return fmt.Errorf("ожидалось число: %q", value)
+	}
+	*target = f
+	return nil
+}
+
+func setRole(target *auth.Role, value string) error {
+	role, err := auth.ParseRole(value)
+	if err != nil {
+		return err
+	}
+	*target = role
+	return nil
+}
+
+// setRoleMap разбирает список вида "значение=роль,значение=роль".
+func setRoleMap(target *map[string]auth.Role, value string) error {
+	roles := make(map[string]auth.Role)
+	for _, item := range splitList(value) {
+		claim, name, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(claim) == "" {
+			return fmt.Errorf("ожидалось значение=роль: %q", item)
+		}
+		role, err := auth.ParseRole(name)
+		if err != nil {
+			return err
+		}
+		roles[strings.TrimSpace(claim)] = role
+	}
+	*target = roles
+	return nil
+}
+
+func setDuration(target *time.Duration, value string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("ожидалась длительность (например, 10s, 720h): %q", value)
+	}
+	*target = d
+	return nil
+}
+
+func setLevel(target *slog.Level, value string) error {
+	level, err := logger.ParseLevel(value)
+	if err != nil {
+		return err
+	}
+	*target = level
+	return nil
+}
+
+func setBool(target *bool, value string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("ожидалось true или false: %q", value)
+	}
+	*target = b
+	return nil
+}
@@ -0,0 +1,201 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"quotes/config"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReadDotEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := `# комментарий
+
+JSONPATH = ./data/quotes.json
+export PORT=9090 # порт
+DSN="user=admin password=\"secret\""
+NAME='Лев # Толстой'
+EMPTY=
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Не удалось записать .env: %v", err)
+	}
+
+	env, err := config.ReadDotEnv(path)
+	if err != nil {
+		t.Fatalf("ReadDotEnv вернула ошибку: %v", err)
+	}
+
+	expected := map[string]string{
+		"JSONPATH": "./data/quotes.json",
+		"PORT":     "9090",
+		"DSN":      `user=admin password="secret"`,
+		"NAME":     "Лев # Толстой",
+		"EMPTY":    "",
+	}
+	for key, value := range expected {
+		if env[key] != value {
+			t.Errorf("%s: ожидалось %q, получено %q", key, value, env[key])
+		}
+	}
+
+	// Строка без "=" считается ошибкой
+	if err = os.WriteFile(path, []byte("INVALID\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать .env: %v", err)
+	}
+	if _, err = config.ReadDotEnv(path); err == nil {
+		t.Error("Ожидалась ошибка для строки без '='")
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 9000\njson_path: from-file.json\ntrash_retention: 48h\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать файл конфигурации: %v", err)
+	}
+
+	envPath := filepath.Join(dir, ".env")
+	if err := os.WriteFile(envPath, []byte("JSONPATH=from-dotenv.json\nSHUTDOWN_TIMEOUT=3s\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать .env: %v", err)
+	}
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "5s")
+
+	cfg, err := config.Load([]string{"-config", configPath, "-env", envPath, "-port", "7000", "-interactive"})
+	if err != nil {
+		t.Fatalf("Load вернула ошибку: %v", err)
+	}
+
+	if cfg.Port != 7000 {
+		t.Errorf("Флаг должен иметь наивысший приоритет, PORT=%d", cfg.Port)
+	}
+	if cfg.JSONPath != "from-dotenv.json" {
+		t.Errorf(".env должен переопределять файл конфигурации, JSONPATH=%s", cfg.JSONPath)
+	}
+	if cfg.ShutdownTimeout != 5*time.Second {
+		t.Errorf("Переменная окружения должна переопределять .env, SHUTDOWN_TIMEOUT=%s", cfg.ShutdownTimeout)
+	}
+	if cfg.TrashRetention != 48*time.Hour {
+		t.Errorf("Ожидалось значение из файла конфигурации, TRASH_RETENTION=%s", cfg.TrashRetention)
+	}
+	if !cfg.Interactive {
+		t.Error("Ожидался интерактивный режим")
+	}
+
+	// Массивы в файле конфигурации соответствуют спискам через запятую
+	for name, content := range map[string]string{
+		"config.yaml": "log_output: [stdout, stderr]\n",
+		"config.toml": "log_output = [\"stdout\", \"stderr\"]\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err = os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось записать файл конфигурации: %v", err)
+		}
+		cfg, err = config.Load([]string{"-config", path, "-env", envPath})
+		if err != nil {
+			t.Fatalf("%s: Load вернула ошибку: %v", name, err)
+		}
+		if !slices.Equal(cfg.LogOutputs, []string{"stdout", "stderr"}) {
+			t.Errorf("%s: ожидалось LOG_OUTPUT=stdout,stderr, получено: %v", name, cfg.LogOutputs)
+		}
+	}
+
+	// Вывод конфигурации по запросу
+	if _, err = config.Load([]string{"-env", envPath, "-print-config"}); !errors.Is(err, config.ErrPrintConfig) {
+		t.Errorf("Ожидалась ErrPrintConfig, получено: %v", err)
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "missing.env")
+
+	// Тест 1: Отсутствующий .env не является ошибкой
+	cfg, err := config.Load([]string{"-env", envPath})
+	if err != nil {
+		t.Fatalf("Load вернула ошибку: %v", err)
+	}
+	if cfg.Port != config.Default().Port {
+		t.Errorf("Ожидался порт по умолчанию, получено: %d", cfg.Port)
+	}
+
+	// Тест 2: Некорректные значения
+	if _, err = config.Load([]string{"-env", envPath, "-port", "70000"}); err == nil {
+		t.Error("Ожидалась ошибка для порта вне диапазона")
+	}
+	if _, err = config.Load([]string{"-env", envPath, "-trash-retention", "month"}); err == nil {
+		t.Error("Ожидалась ошибка для некорректной длительности")
+	}
+
+	// Тест 3: Неизвестный параметр в файле конфигурации
+	configPath := filepath.Join(dir, "config.toml")
+	if err = os.WriteFile(configPath, []byte("prot = 8080\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать файл конфигурации: %v", err)
+	}
+	if _, err = config.Load([]string{"-env", envPath, "-config", configPath}); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного параметра")
+	}
+}
+
+func TestReload(t *testing.T) {
+	envPath := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envPath, []byte("PORT=8080\nTRASH_RETENTION=24h\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать .env: %v", err)
+	}
+
+	args := []string{"-env", envPath}
+	cfg, err := config.Load(args)
+	if err != nil {
+		t.Fatalf("Load вернула ошибку: %v", err)
+	}
+
+	reloader := config.NewReloader(cfg, args)
+	notified, reloaded := 0, 0
+	reloader.OnChange(func(cfg *config.Config) { notified++ })
+	reloader.OnReload(func(cfg *config.Config) { reloaded++ })
+
+	// Тест 1: Параметры, изменяемые на лету, применяются, остальные ждут перезапуска
+	if err = os.WriteFile(envPath, []byte("PORT=9090\nTRASH_RETENTION=48h\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать .env: %v", err)
+	}
+	changes, err := reloader.Reload()
+	if err != nil {
+		t.Fatalf("Reload вернула ошибку: %v", err)
+	}
+	if len(changes) != 2 || notified != 1 {
+		t.Fatalf("Ожидалось 2 изменения и 1 уведомление, получено: %+v, %d", changes, notified)
+	}
+	if reloader.Current().TrashRetention != 48*time.Hour {
+		t.Errorf("TRASH_RETENTION не применен: %s", reloader.Current().TrashRetention)
+	}
+	if reloader.Current().Port != 8080 {
+		t.Errorf("PORT не должен меняться без перезапуска: %d", reloader.Current().Port)
+	}
+
+	// Тест 2: Некорректная конфигурация отклоняется
+	if err = os.WriteFile(envPath, []byte("TRASH_RETENTION=-1h\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать .env: %v", err)
+	}
+	if _, err = reloader.Reload(); err == nil {
+		t.Error("Ожидалась ошибка для некорректной конфигурации")
+	}
+	if reloader.Current().TrashRetention != 48*time.Hour {
+		t.Errorf("Текущая конфигурация не должна меняться: %s", reloader.Current().TrashRetention)
+	}
+
+	// Тест 3: OnReload вызывается и без изменений, OnChange - только при изменениях
+	if err = os.WriteFile(envPath, []byte("PORT=8080\nTRASH_RETENTION=48h\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать .env: %v", err)
+	}
+	if _, err = reloader.Reload(); err != nil {
+		t.Fatalf("Reload вернула ошибку: %v", err)
+	}
+	if notified != 1 || reloaded != 2 {
+		t.Errorf("Ожидалось 1 уведомление об изменении и 2 о перезагрузке, получено: %d, %d", notified, reloaded)
+	}
+}
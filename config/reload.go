@@ -0,0 +1,99 @@
+package config
+
+import (
+	"sync"
+)
+
+type Change struct {
+	Key     string `json:"key"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	Applied bool   `json:"applied"`
+}
+
+// Reloader хранит текущую конфигурацию и перечитывает ее по запросу.
+// Параметры, которые нельзя изменить на лету, сохраняют прежние значения
+// до перезапуска.
+type Reloader struct {
+	mu        sync.RWMutex
+	current   *Config
+	args      []string
+	watchers  []func(cfg *Config)
+	reloaders []func(cfg *Config)
+}
+
+func NewReloader(cfg *Config, args []string) *Reloader {
+	return &Reloader{current: cfg, args: args}
+}
+
+func (r *Reloader) Current() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current
+}
+
+// OnChange регистрирует функцию, вызываемую после каждой успешной
+// перезагрузки, изменившей хотя бы один параметр.
+func (r *Reloader) OnChange(fn func(cfg *Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.watchers = append(r.watchers, fn)
+}
+
+// OnReload регистрирует функцию, вызываемую после каждой успешной
+// перезагрузки, даже если параметры не изменились. Подходит для того, чтобы
+// заново прочитать файлы, на которые указывает конфигурация.
+func (r *Reloader) OnReload(fn func(cfg *Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reloaders = append(r.reloaders, fn)
+}
+
+// Reload перечитывает конфигурацию из всех источников. Некорректная новая
+// конфигурация отклоняется, текущая при этом не меняется.
+func (r *Reloader) Reload() ([]Change, error) {
+	loaded, err := Load(r.args)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	old := r.current
+	next := *old
+	next.LogOutputs = append([]string(nil), old.LogOutputs...)
+	changes := []Change{}
+
+	for i := range settings {
+		s := &settings[i]
+		oldValue, newValue := s.get(old), s.get(loaded)
+		if oldValue == newValue {
+			continue
+		}
+
+		changes = append(changes, Change{Key: s.env, Old: oldValue, New: newValue, Applied: s.live})
+		if s.live {
+			if err = s.set(&next, newValue); err != nil {
+				r.mu.Unlock()
+				return nil, err
+			}
+		}
+	}
+
+	r.current = &next
+	watchers, reloaders := r.watchers, r.reloaders
+	r.mu.Unlock()
+
+	if len(changes) > 0 {
+		for _, watcher := range watchers {
+			watcher(&next)
+		}
+	}
+	for _, reloader := range reloaders {
+		reloader(&next)
+	}
+
+	return changes, nil
+}
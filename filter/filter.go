@@ -0,0 +1,206 @@
+// Package filter проверяет текст цитат цепочкой правил перед сохранением.
+// Каждое правило находит в тексте нарушения, а действие правила решает, что с
+// ними делать: отклонить цитату, отправить ее на модерацию или замаскировать
+// найденное.
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Action string
+
+const (
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+	ActionMask   Action = "mask"
+)
+
+// severity упорядочивает действия: из нескольких сработавших правил итог
+// определяет самое строгое.
+var severity = map[Action]int{
+	ActionMask:   1,
+	ActionFlag:   2,
+	ActionReject: 3,
+}
+
+func ParseAction(value string) (Action, error) {
+	action := Action(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := severity[action]; !ok {
+		return "", fmt.Errorf("Неизвестное действие %q, ожидается reject, flag или mask", value)
+	}
+	return action, nil
+}
+
+// Match найденный правилом фрагмент текста: байтовые границы [Start, End) и
+// замена, которой фрагмент маскируется.
+type Match struct {
+	Start   int
+	End     int
+	Replace string
+}
+
+// Rule правило фильтра. Match возвращает непересекающиеся фрагменты текста
+// в порядке их следования.
+type Rule interface {
+	Name() string
+	Match(text string) []Match
+}
+
+// Violation сработавшее правило.
+type Violation struct {
+	Rule   string `json:"rule"`
+	Action Action `json:"action"`
+	Match  string `json:"match"`
+}
+
+// Result итог проверки: текст после маскирования, самое строгое из
+// сработавших действий (пустое, если нарушений нет) и сами нарушения.
+type Result struct {
+	Text       string
+	Action     Action
+	Violations []Violation
+}
+
+// RejectedError отказ фильтра с причиной, которая возвращается клиенту.
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "Текст отклонен фильтром содержимого: " + e.Reason
+}
+
+type step struct {
+	rule   Rule
+	action Action
+}
+
+// Chain цепочка правил. Правила применяются по порядку, и маскирующее
+// правило передает следующим уже измененный текст. Нулевая и nil цепочки
+// ничего не проверяют.
+type Chain struct {
+	mu    sync.RWMutex
+	steps []step
+}
+
+// Use добавляет правило в конец цепочки.
+func (c *Chain) Use(rule Rule, action Action) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.steps = append(c.steps, step{rule: rule, action: action})
+}
+
+// Len возвращает число правил в цепочке.
+func (c *Chain) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.steps)
+}
+
+// Reload заменяет правила цепочки правилами из файла path. При ошибке
+// прежние правила сохраняются.
+func (c *Chain) Reload(path string) error {
+	loaded, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.steps = loaded.steps
+	return nil
+}
+
+func (c *Chain) Check(text string) Result {
+	result := Result{Text: text}
+	if c == nil {
+		return result
+	}
+
+	c.mu.RLock()
+	steps := c.steps
+	c.mu.RUnlock()
+
+	for _, step := range steps {
+		matches := step.rule.Match(result.Text)
+		if len(matches) == 0 {
+			continue
+		}
+
+		for _, match := range matches {
+			result.Violations = append(result.Violations, Violation{
+				Rule:   step.rule.Name(),
+				Action: step.action,
+				Match:  result.Text[match.Start:match.End],
+			})
+		}
+		if severity[step.action] > severity[result.Action] {
+			result.Action = step.action
+		}
+		if step.action == ActionMask {
+			result.Text = mask(result.Text, matches)
+		}
+	}
+
+	return result
+}
+
+// Rejection возвращает RejectedError по первому отклонившему правилу или nil.
+func (result Result) Rejection() error {
+	for _, violation := range result.Violations {
+		if violation.Action == ActionReject {
+			return &RejectedError{Reason: fmt.Sprintf("правило %s, фрагмент %q", violation.Rule, violation.Match)}
+		}
+	}
+	return nil
+}
+
+// Flags возвращает имена правил, потребовавших модерации, без повторов.
+func (result Result) Flags() []string {
+	var flags []string
+	for _, violation := range result.Violations {
+		if violation.Action == ActionFlag && !contains(flags, violation.Rule) {
+			flags = append(flags, violation.Rule)
+		}
+	}
+	return flags
+}
+
+// mask заменяет фрагменты их заменами.
+func mask(text string, matches []Match) string {
+	sorted := append([]Match(nil), matches...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var b strings.Builder
+	last := 0
+	for _, match := range sorted {
+		if match.Start < last {
+			continue
+		}
+		b.WriteString(text[last:match.Start])
+		b.WriteString(match.Replace)
+		last = match.End
+	}
+	b.WriteString(text[last:])
+
+	return b.String()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package filter_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"quotes/filter"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	ru := filter.NewWords("words_ru", "ru", []string{"дурак", "спам*"})
+	en := filter.NewWords("words_en", "en", []string{"idiot", "spam"})
+
+	tests := []struct {
+		name string
+		rule filter.Rule
+		text string
+		want int
+	}{
+		{"Словоформа", ru, "Не будь дураком", 1},
+		{"Множественное число", ru, "Одни дураки вокруг", 1},
+		{"Ё и регистр", ru, "ДУРАКИ", 1},
+		{"Латиница вместо кириллицы", ru, "дурaк", 1},
+		{"Префикс", ru, "Спамеры и спамерские рассылки", 2},
+		{"Чистый текст", ru, "Дорогу осилит идущий", 0},
+		{"Английский", en, "Only idiots and spammers", 2},
+		{"Английское слово внутри другого", en, "Idiotic", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Match(tt.text); len(got) != tt.want {
+				t.Errorf("Ожидалось совпадений: %d, получено: %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	rule := filter.NewLinks("links")
+
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"Подробнее на https://example.com/promo", 1},
+		{"Заходите на example.ru", 1},
+		{"Пишите: spam@example.com", 1},
+		{"Звоните +7 (999) 123-45-67", 1},
+		{"Подписывайтесь @quotes_channel", 1},
+		{"Война 1812 года и 1941-1945 годы", 0},
+		{"Т.е. никаких ссылок", 0},
+	}
+
+	for _, tt := range tests {
+		if got := rule.Match(tt.text); len(got) != tt.want {
+			t.Errorf("%q: ожидалось совпадений: %d, получено: %+v", tt.text, tt.want, got)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	var chain filter.Chain
+	chain.Use(filter.NewRepetition("repetition", 3, 2), filter.ActionMask)
+	chain.Use(filter.NewCaps("caps", 10, 0.7), filter.ActionMask)
+	chain.Use(filter.NewWords("words_ru", "ru", []string{"дурак"}), filter.ActionMask)
+	chain.Use(filter.NewLinks("links"), filter.ActionFlag)
+
+	// Тест 1: Маскирующие правила применяются по очереди
+	result := chain.Check("ЭТО ПИШЕТ ДУРАК!!!!!!")
+	if result.Text != "это пишет *****!!!" || result.Action != filter.ActionMask {
+		t.Errorf("Неожиданный результат: %+v", result)
+	}
+
+	// Тест 2: Повторы слов сокращаются
+	if result = chain.Check("купи купи купи купи"); result.Text != "купи купи" {
+		t.Errorf("Ожидалось 'купи купи', получено: %q", result.Text)
+	}
+
+	// Тест 3: Итоговое действие - самое строгое из сработавших
+	result = chain.Check("Дурак, зайди на example.com")
+	if result.Action != filter.ActionFlag || len(result.Flags()) != 1 || result.Flags()[0] != "links" {
+		t.Errorf("Ожидалась пометка links, получено: %+v", result)
+	}
+	if result.Rejection() != nil {
+		t.Error("Помеченный текст не должен отклоняться")
+	}
+
+	// Тест 4: Отклонение возвращает причину
+	chain.Use(filter.NewWords("words_en", "en", []string{"scam"}), filter.ActionReject)
+	var rejected *filter.RejectedError
+	if err := chain.Check("Total scamming").Rejection(); !errors.As(err, &rejected) {
+		t.Errorf("Ожидалась ошибка RejectedError, получено: %v", err)
+	}
+
+	// Тест 5: Пустая цепочка ничего не меняет
+	var empty *filter.Chain
+	if result = empty.Check("ЛЮБОЙ ТЕКСТ!!!!!"); result.Text != "ЛЮБОЙ ТЕКСТ!!!!!" || result.Action != "" {
+		t.Errorf("Пустая цепочка изменила текст: %+v", result)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось записать файл: %v", err)
+		}
+		return path
+	}
+
+	write("words_ru.txt", "# Бранные слова\nдурак\n\nспам*\n")
+	path := write("rules.json", `{"rules": [
+		{"type": "words", "lang": "ru", "file": "words_ru.txt", "action": "reject"},
+		{"type": "links", "action": "flag"},
+		{"type": "repetition", "action": "mask"},
+		{"type": "caps", "name": "shouting", "action": "mask", "min_letters": 5}
+	]}`)
+
+	// Тест 1: Правила и списки слов читаются из файлов
+	chain, err := filter.Load(path)
+	if err != nil || chain.Len() != 4 {
+		t.Fatalf("Ожидалось 4 правила, получено: %d, %v", chain.Len(), err)
+	}
+	if err = chain.Check("Спамер").Rejection(); err == nil {
+		t.Error("Ожидалось отклонение по списку слов")
+	}
+
+	// Тест 2: Некорректные правила отклоняются
+	for _, rules := range []string{
+		`{"rules": [{"type": "unknown", "action": "reject"}]}`,
+		`{"rules": [{"type": "links", "action": "ban"}]}`,
+		`{"rules": [{"type": "words", "lang": "de", "file": "words_ru.txt", "action": "mask"}]}`,
+		`{"rules": [{"type": "words", "lang": "ru", "file": "missing.txt", "action": "mask"}]}`,
+		`{"rules": [{"type": "caps", "max_ratio": 2, "action": "mask"}]}`,
+	} {
+		if _, err = filter.Load(write("bad.json", rules)); err == nil {
+			t.Errorf("Ожидалась ошибка для правил %s", rules)
+		}
+	}
+
+	// Тест 3: При ошибке перезагрузки прежние правила сохраняются
+	if err = chain.Reload(filepath.Join(dir, "bad.json")); err == nil || chain.Len() != 4 {
+		t.Errorf("Ожидалась ошибка и прежние правила, получено: %d, %v", chain.Len(), err)
+	}
+	if err = chain.Reload(""); err != nil || chain.Len() != 0 {
+		t.Errorf("Пустой путь должен отключать фильтр, получено: %d, %v", chain.Len(), err)
+	}
+}
@@ -0,0 +1,140 @@
+package filter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ruleConfig описание правила в файле правил. Параметры, не относящиеся к
+// типу правила, игнорируются.
+type ruleConfig struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	// Lang и File для правила words: язык и файл со списком слов, путь
+	// относительно файла правил.
+	Lang string `json:"lang"`
+	File string `json:"file"`
+	// MaxRepeat и MaxWords для правила repetition.
+	MaxRepeat int `json:"max_repeat"`
+	MaxWords  int `json:"max_words"`
+	// MinLetters и MaxRatio для правила caps.
+	MinLetters int     `json:"min_letters"`
+	MaxRatio   float64 `json:"max_ratio"`
+}
+
+// Load читает цепочку правил из JSON-файла path. Пустой path дает пустую
+// цепочку.
+func Load(path string) (*Chain, error) {
+	chain := &Chain{}
+	if path == "" {
+		return chain, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось прочитать правила фильтра: %w", err)
+	}
+
+	var file struct {
+		Rules []ruleConfig `json:"rules"`
+	}
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Не удалось десериализовать правила фильтра: %w", err)
+	}
+
+	for i, cfg := range file.Rules {
+		rule, err := cfg.build(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("Правило фильтра %d: %w", i+1, err)
+		}
+		action, err := ParseAction(cfg.Action)
+		if err != nil {
+			return nil, fmt.Errorf("Правило фильтра %s: %w", rule.Name(), err)
+		}
+		chain.Use(rule, action)
+	}
+
+	return chain, nil
+}
+
+func (cfg ruleConfig) build(dir string) (Rule, error) {
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Type
+		if cfg.Lang != "" {
+			name += "_" + cfg.Lang
+		}
+	}
+
+	switch cfg.Type {
+	case "words":
+		if cfg.Lang != "ru" && cfg.Lang != "en" {
+			return nil, fmt.Errorf("Язык списка слов должен быть ru или en: %q", cfg.Lang)
+		}
+		path := cfg.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		words, err := readWords(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewWords(name, cfg.Lang, words), nil
+	case "links":
+		return NewLinks(name), nil
+	case "repetition":
+		if cfg.MaxRepeat == 0 {
+			cfg.MaxRepeat = 4
+		}
+		if cfg.MaxWords == 0 {
+			cfg.MaxWords = 3
+		}
+		if cfg.MaxRepeat < 1 || cfg.MaxWords < 1 {
+			return nil, fmt.Errorf("max_repeat и max_words должны быть положительными")
+		}
+		return NewRepetition(name, cfg.MaxRepeat, cfg.MaxWords), nil
+	case "caps":
+		if cfg.MinLetters == 0 {
+			cfg.MinLetters = 12
+		}
+		if cfg.MaxRatio == 0 {
+			cfg.MaxRatio = 0.7
+		}
+		if cfg.MinLetters < 1 || cfg.MaxRatio <= 0 || cfg.MaxRatio >= 1 {
+			return nil, fmt.Errorf("min_letters должен быть положительным, max_ratio - от 0 до 1")
+		}
+		return NewCaps(name, cfg.MinLetters, cfg.MaxRatio), nil
+	default:
+		return nil, fmt.Errorf("Неизвестный тип правила %q, ожидается words, links, repetition или caps", cfg.Type)
+	}
+}
+
+// readWords читает список слов: по слову в строке, пустые строки и строки,
+// начинающиеся с #, пропускаются.
+func readWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось открыть список слов: %w", err)
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Не удалось прочитать список слов: %w", err)
+	}
+
+	return words, nil
+}
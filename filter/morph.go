@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token слово текста с байтовыми границами.
+type token struct {
+	start, end int
+	word       string
+}
+
+// tokenize разбивает текст на слова из букв.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, c := range text {
+		if unicode.IsLetter(c) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{start: start, end: i, word: text[start:i]})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start: start, end: len(text), word: text[start:]})
+	}
+	return tokens
+}
+
+// homoglyphs латинские буквы, которыми подменяют похожие кириллические,
+// чтобы обойти фильтр.
+var homoglyphs = map[rune]rune{
+	'a': 'а', 'b': 'в', 'c': 'с', 'e': 'е', 'h': 'н', 'k': 'к', 'm': 'м',
+	'o': 'о', 'p': 'р', 't': 'т', 'x': 'х', 'y': 'у',
+}
+
+// normalize приводит слово к нижнему регистру и заменяет ё на е. Если в
+// слове есть кириллица, латинские буквы-двойники заменяются кириллическими.
+func normalize(word string) string {
+	word = strings.ToLower(word)
+	word = strings.ReplaceAll(word, "ё", "е")
+
+	cyrillic := false
+	for _, c := range word {
+		if unicode.Is(unicode.Cyrillic, c) {
+			cyrillic = true
+			break
+		}
+	}
+	if !cyrillic {
+		return word
+	}
+
+	return strings.Map(func(c rune) rune {
+		if replacement, ok := homoglyphs[c]; ok {
+			return replacement
+		}
+		return c
+	}, word)
+}
+
+// minStem минимальная длина основы в буквах: более короткие слова не
+// сокращаются, чтобы разные слова не сводились к одной основе.
+const minStem = 3
+
+// Окончания, отбрасываемые при поиске основы, длинные первыми.
+var (
+	endingsRU = []string{
+		"иями", "ями", "ами", "иях", "ого", "его", "ому", "ему", "ыми", "ими",
+		"ешь", "ете", "ишь", "ите", "ать", "ять", "ить", "еть", "уть",
+		"ая", "яя", "ое", "ее", "ые", "ие", "ый", "ий", "ой", "ей", "ом", "ем",
+		"ам", "ям", "ах", "ях", "ов", "ев", "ью", "ую", "юю", "ют", "ут", "ат",
+		"ят", "ит", "ет", "ла", "ло", "ли", "ть",
+		"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+	}
+	endingsEN = []string{"ings", "ing", "ers", "er", "ed", "es", "s"}
+)
+
+// stem возвращает основу нормализованного слова для языка lang ("ru" или
+// "en"): отбрасывает самое длинное окончание словоизменения, оставляя не
+// меньше minStem букв. Так "дураки" и "дураком" сводятся к "дурак", а
+// "spammers" и "spamming" - к "spam".
+func stem(word, lang string) string {
+	endings := endingsRU
+	if lang == "en" {
+		word = strings.TrimSuffix(word, "'s")
+		endings = endingsEN
+	}
+
+	for _, ending := range endings {
+		if !strings.HasSuffix(word, ending) {
+			continue
+		}
+		base := strings.TrimSuffix(word, ending)
+		if utf8.RuneCountInString(base) < minStem {
+			continue
+		}
+		if lang == "en" && ending != "s" && ending != "es" {
+			base = undouble(base)
+		}
+		return base
+	}
+
+	return word
+}
+
+// undouble убирает удвоенную согласную на конце английской основы:
+// spamm -> spam.
+func undouble(base string) string {
+	n := len(base)
+	if n < 2 || base[n-1] != base[n-2] || strings.ContainsRune("aeiouls", rune(base[n-1])) {
+		return base
+	}
+	return base[:n-1]
+}
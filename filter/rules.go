@@ -0,0 +1,242 @@
+package filter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Words находит слова из списка с учетом словоизменения: слова списка и
+// текста сравниваются по основам, см. stem. Слово списка со звездочкой на
+// конце ("спам*") совпадает со всеми словами, начинающимися с него.
+type Words struct {
+	name     string
+	lang     string
+	stems    map[string]bool
+	prefixes []string
+}
+
+// NewWords создает правило для списка слов на языке lang ("ru" или "en").
+func NewWords(name, lang string, words []string) *Words {
+	rule := &Words{name: name, lang: lang, stems: make(map[string]bool)}
+	for _, word := range words {
+		word = normalize(strings.TrimSpace(word))
+		switch {
+		case word == "":
+		case strings.HasSuffix(word, "*"):
+			rule.prefixes = append(rule.prefixes, strings.TrimSuffix(word, "*"))
+		default:
+			rule.stems[stem(word, lang)] = true
+		}
+	}
+	return rule
+}
+
+func (rule *Words) Name() string {
+	return rule.name
+}
+
+func (rule *Words) Match(text string) []Match {
+	var matches []Match
+	for _, token := range tokenize(text) {
+		if rule.matches(normalize(token.word)) {
+			matches = append(matches, Match{
+				Start:   token.start,
+				End:     token.end,
+				Replace: stars(token.word),
+			})
+		}
+	}
+	return matches
+}
+
+func (rule *Words) matches(word string) bool {
+	if rule.stems[stem(word, rule.lang)] {
+		return true
+	}
+	for _, prefix := range rule.prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Шаблоны ссылок и контактов. Телефон дополнительно проверяется по числу
+// цифр, чтобы не путать его с годами и датами.
+var (
+	urlPattern    = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s]+`)
+	domainPattern = regexp.MustCompile(`(?i)\b[a-z0-9][a-z0-9-]*(?:\.[a-z0-9-]+)*\.(?:com|net|org|ru|su|io|me|info|biz|xyz|online|site|top|shop|club|pro)\b(?:/[^\s]*)?|[а-яё0-9-]+\.рф`)
+	emailPattern  = regexp.MustCompile(`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`)
+	phonePattern  = regexp.MustCompile(`\+?\d[\d\s().-]{8,}\d`)
+	handlePattern = regexp.MustCompile(`(?:^|\s)(@[A-Za-z0-9_]{4,})`)
+)
+
+const minPhoneDigits = 10
+
+// Links находит ссылки, адреса электронной почты, телефоны и имена
+// пользователей мессенджеров.
+type Links struct {
+	name string
+}
+
+func NewLinks(name string) *Links {
+	return &Links{name: name}
+}
+
+func (rule *Links) Name() string {
+	return rule.name
+}
+
+func (rule *Links) Match(text string) []Match {
+	var spans [][]int
+	spans = append(spans, urlPattern.FindAllStringIndex(text, -1)...)
+	spans = append(spans, domainPattern.FindAllStringIndex(text, -1)...)
+	spans = append(spans, emailPattern.FindAllStringIndex(text, -1)...)
+	for _, span := range phonePattern.FindAllStringIndex(text, -1) {
+		digits := 0
+		for _, c := range text[span[0]:span[1]] {
+			if c >= '0' && c <= '9' {
+				digits++
+			}
+		}
+		if digits >= minPhoneDigits {
+			spans = append(spans, span)
+		}
+	}
+	for _, span := range handlePattern.FindAllStringSubmatchIndex(text, -1) {
+		spans = append(spans, span[2:4])
+	}
+
+	return merge(text, spans)
+}
+
+// merge объединяет пересекающиеся фрагменты и маскирует их звездочками.
+func merge(text string, spans [][]int) []Match {
+	var matches []Match
+	for _, span := range sortSpans(spans) {
+		if n := len(matches); n > 0 && span[0] < matches[n-1].End {
+			if span[1] > matches[n-1].End {
+				matches[n-1].End = span[1]
+				matches[n-1].Replace = stars(text[matches[n-1].Start:span[1]])
+			}
+			continue
+		}
+		matches = append(matches, Match{Start: span[0], End: span[1], Replace: stars(text[span[0]:span[1]])})
+	}
+	return matches
+}
+
+func sortSpans(spans [][]int) [][]int {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	return spans
+}
+
+// Repetition находит символ, повторенный больше maxRepeat раз подряд
+// ("ааааа", "!!!!!"), и слово, повторенное больше maxWords раз подряд.
+// Маскирование оставляет только допустимое число повторов. Цифры и пробелы не
+// проверяются.
+type Repetition struct {
+	name      string
+	maxRepeat int
+	maxWords  int
+}
+
+func NewRepetition(name string, maxRepeat, maxWords int) *Repetition {
+	return &Repetition{name: name, maxRepeat: maxRepeat, maxWords: maxWords}
+}
+
+func (rule *Repetition) Name() string {
+	return rule.name
+}
+
+func (rule *Repetition) Match(text string) []Match {
+	var spans [][]int
+
+	var prev rune
+	count, keepEnd := 0, 0
+	for i, c := range text {
+		if c == prev && !unicode.IsDigit(c) && !unicode.IsSpace(c) {
+			count++
+		} else {
+			if count > rule.maxRepeat {
+				spans = append(spans, []int{keepEnd, i})
+			}
+			prev, count = c, 1
+		}
+		if count == rule.maxRepeat {
+			keepEnd = i + utf8.RuneLen(c)
+		}
+	}
+	if count > rule.maxRepeat {
+		spans = append(spans, []int{keepEnd, len(text)})
+	}
+
+	tokens := tokenize(text)
+	for i := 0; i < len(tokens); {
+		j := i + 1
+		for j < len(tokens) && normalize(tokens[j].word) == normalize(tokens[i].word) {
+			j++
+		}
+		if j-i > rule.maxWords {
+			spans = append(spans, []int{tokens[i+rule.maxWords-1].end, tokens[j-1].end})
+		}
+		i = j
+	}
+
+	var matches []Match
+	for _, span := range sortSpans(spans) {
+		if n := len(matches); n > 0 && span[0] < matches[n-1].End {
+			continue
+		}
+		matches = append(matches, Match{Start: span[0], End: span[1]})
+	}
+	return matches
+}
+
+// Caps находит текст, написанный заглавными буквами: если в тексте не меньше
+// minLetters букв и доля заглавных больше maxRatio, совпадением считается
+// каждое слово из заглавных. Маскирование переводит их в нижний регистр.
+type Caps struct {
+	name       string
+	minLetters int
+	maxRatio   float64
+}
+
+func NewCaps(name string, minLetters int, maxRatio float64) *Caps {
+	return &Caps{name: name, minLetters: minLetters, maxRatio: maxRatio}
+}
+
+func (rule *Caps) Name() string {
+	return rule.name
+}
+
+func (rule *Caps) Match(text string) []Match {
+	letters, upper := 0, 0
+	for _, c := range text {
+		if unicode.IsLetter(c) {
+			letters++
+			if unicode.IsUpper(c) {
+				upper++
+			}
+		}
+	}
+	if letters < rule.minLetters || float64(upper)/float64(letters) <= rule.maxRatio {
+		return nil
+	}
+
+	var matches []Match
+	for _, token := range tokenize(text) {
+		if utf8.RuneCountInString(token.word) > 1 && token.word == strings.ToUpper(token.word) {
+			matches = append(matches, Match{Start: token.start, End: token.end, Replace: strings.ToLower(token.word)})
+		}
+	}
+	return matches
+}
+
+// stars заменяет каждый символ фрагмента звездочкой.
+func stars(fragment string) string {
+	return strings.Repeat("*", utf8.RuneCountInString(fragment))
+}
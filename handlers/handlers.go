@@ -2,18 +2,35 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"quotes/audit"
+	"quotes/auth"
+	"quotes/config"
+	"quotes/filter"
 	"quotes/logger"
 	"quotes/services"
 	"quotes/storage"
+	"time"
 )
 
-func HandlerQuotesPost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+// HandlerQuotesPost добавляет цитату или, если moderation возвращает true и
+// клиент не модератор либо цитату пометил фильтр содержимого, отправляет ее в
+// очередь модерации.
+func HandlerQuotesPost(s *storage.JSONStorage, filters *filter.Chain, moderation func() bool, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := services.Add(s, r, log); err != nil {
-			log.Error(err.Error())
-			http.Error(w, "Internal Server Error", http.StatusBadRequest)
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Add(s, filters, services.Moderated(r, moderation()), r, log)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		if quote.Status == storage.StatusPending {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("Цитата отправлена на модерацию"))
 			return
 		}
 
@@ -24,20 +41,27 @@ func HandlerQuotesPost(s *storage.JSONStorage, log *logger.Logger) http.HandlerF
 
 func HandlerQuotesGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var quotes []storage.Quote
+		log := log.WithContext(r.Context())
+
+		var quotes []storage.QuoteStore
 		var err error
 
 		quotes, err = services.GetQuotes(s, log, r)
 		if err != nil {
-			log.Error(fmt.Sprintf("Ошибка при получении цитат: %v", err))
+			log.Error("Ошибка при получении цитат", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
+		w.Header().Set("Vary", "Accept-Language")
+		if notModified(w, r, services.ListETag(quotes)) {
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 
 		if err := json.NewEncoder(w).Encode(quotes); err != nil {
-			log.Error(fmt.Sprintf("Ошибка при записи ответа: %v", err))
+			log.Error("Ошибка при записи ответа", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
@@ -46,17 +70,20 @@ func HandlerQuotesGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFu
 
 func HandlerQuotesRandomGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		quote, err := services.GetRandom(s, log)
+		log := log.WithContext(r.Context())
+
+		quote, err := services.GetRandom(s, log, r)
 		if err != nil {
-			log.Error(fmt.Sprintf("Ошибка при получении рандомной цитаты: %v", err))
+			log.Error("Ошибка при получении рандомной цитаты", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
 
+		w.Header().Set("Vary", "Accept-Language")
 		w.Header().Set("Content-Type", "application/json")
 
 		if err := json.NewEncoder(w).Encode(quote); err != nil {
-			log.Error(fmt.Sprintf("Ошибка при записи ответа: %v", err))
+			log.Error("Ошибка при записи ответа", "error", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
@@ -65,9 +92,11 @@ func HandlerQuotesRandomGet(s *storage.JSONStorage, log *logger.Logger) http.Han
 
 func HandlerQuotesDelete(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
 		if err := services.Delete(s, log, r); err != nil {
-			log.Error(err.Error())
-			http.Error(w, "Bad request", http.StatusBadRequest)
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
 			return
 		}
 
@@ -75,3 +104,706 @@ func HandlerQuotesDelete(s *storage.JSONStorage, log *logger.Logger) http.Handle
 		w.Write([]byte("Цитата успешно удалена"))
 	}
 }
+
+func HandlerAuthorsSuggest(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		suggestions, err := services.SuggestAuthors(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, log, suggestions)
+	}
+}
+
+func HandlerStatsGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		stats, err := services.GetStats(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, log, stats)
+	}
+}
+
+func HandlerQuoteGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.GetQuote(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		if notModified(w, r, services.ETag(quote)) {
+			return
+		}
+
+		writeJSON(w, log, quote)
+	}
+}
+
+// HandlerQuotesPut заменяет цитату. Если moderation возвращает true, а клиент
+// не модератор, цитата возвращается в очередь модерации.
+func HandlerQuotesPut(s *storage.JSONStorage, filters *filter.Chain, moderation func() bool, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Update(s, filters, services.Moderated(r, moderation()), log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", services.ETag(quote))
+		writeJSONStatus(w, log, updateStatus(quote), quote)
+	}
+}
+
+func HandlerQuotesPatch(s *storage.JSONStorage, filters *filter.Chain, moderation func() bool, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Patch(s, filters, services.Moderated(r, moderation()), log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", services.ETag(quote))
+		writeJSONStatus(w, log, updateStatus(quote), quote)
+	}
+}
+
+func HandlerQuotesHistoryGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		revisions, err := services.GetHistory(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, revisions)
+	}
+}
+
+func HandlerQuotesRevertPost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Revert(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, quote)
+	}
+}
+
+func HandlerTrashGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		writeJSON(w, log, services.GetTrash(s, log, r))
+	}
+}
+
+func HandlerTrashRestorePost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Restore(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, quote)
+	}
+}
+
+// updateStatus возвращает 202 для изменения, отправленного на модерацию.
+func updateStatus(quote storage.QuoteStore) int {
+	if quote.Status == storage.StatusPending {
+		return http.StatusAccepted
+	}
+	return http.StatusOK
+}
+
+func writeJSON(w http.ResponseWriter, log *logger.Logger, v any) {
+	writeJSONStatus(w, log, http.StatusOK, v)
+}
+
+func writeJSONStatus(w http.ResponseWriter, log *logger.Logger, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("Ошибка при записи ответа", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// notModified выставляет ETag и отвечает 304, если он совпал с If-None-Match.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	if header := r.Header.Get("If-None-Match"); header != "" && services.MatchesETag(header, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// writeError отвечает статусом, соответствующим ошибке. При отказе в доступе
+// или отказе фильтра содержимого клиент получает причину отказа.
+func writeError(w http.ResponseWriter, err error) {
+	var forbidden *auth.ForbiddenError
+	if errors.As(err, &forbidden) {
+		http.Error(w, forbidden.Reason, http.StatusForbidden)
+		return
+	}
+	var rejected *filter.RejectedError
+	if errors.As(err, &rejected) {
+		http.Error(w, rejected.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	status := errorStatus(err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrQuoteNotFound), errors.Is(err, auth.ErrKeyNotFound),
+		errors.Is(err, storage.ErrUserNotFound), errors.Is(err, storage.ErrCollectionNotFound),
+		errors.Is(err, storage.ErrReportNotFound), errors.Is(err, storage.ErrTranslationNotFound),
+		errors.Is(err, storage.ErrRevisionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrUserExists), errors.Is(err, storage.ErrCollectionExists),
+		errors.Is(err, storage.ErrDuplicateReport):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidCredentials), errors.Is(err, storage.ErrSessionNotFound):
+		return http.StatusUnauthorized
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func HandlerConfigGet(reloader *config.Reloader, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		writeJSON(w, log, reloader.Current().Values())
+	}
+}
+
+func HandlerConfigReloadPost(reloader *config.Reloader, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		changes, err := services.ReloadConfig(reloader, log)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		writeJSON(w, log, changes)
+	}
+}
+
+func HandlerAuditGet(a *audit.Log, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		records, err := services.QueryAudit(a, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, log, records)
+	}
+}
+
+// HandlerHealthz отвечает 200, пока процесс жив.
+func HandlerHealthz(log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok"))
+	}
+}
+
+// HandlerReadyz отвечает 200, если сервис готов принимать запросы, и 503
+// со списком непройденных проверок в противном случае.
+func HandlerReadyz(s *storage.JSONStorage, h services.Health, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		readiness := services.CheckReadiness(s, h, log)
+		if !readiness.Ready {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(readiness)
+			return
+		}
+
+		writeJSON(w, log, readiness)
+	}
+}
+
+func HandlerStatusGet(s *storage.JSONStorage, h services.Health, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		writeJSON(w, log, services.GetStatus(s, h, log))
+	}
+}
+
+func HandlerKeysGet(k *auth.KeyStore, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		writeJSON(w, log, services.ListKeys(k, log))
+	}
+}
+
+func HandlerKeysPost(k *auth.KeyStore, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		created, err := services.CreateKey(k, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		writeJSONStatus(w, log, http.StatusCreated, created)
+	}
+}
+
+func HandlerKeysDelete(k *auth.KeyStore, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		key, err := services.RevokeKey(k, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, key)
+	}
+}
+
+func HandlerRegisterPost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		user, err := services.Register(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSONStatus(w, log, http.StatusCreated, user)
+	}
+}
+
+func HandlerLoginPost(s *storage.JSONStorage, ttl time.Duration, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		login, err := services.Login(s, ttl, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, login)
+	}
+}
+
+func HandlerLogoutPost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		if err := services.Logout(s, log, r); err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func HandlerMeGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		user, err := services.GetMe(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, user)
+	}
+}
+
+func HandlerFavoritesGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quotes, err := services.GetFavorites(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, quotes)
+	}
+}
+
+func HandlerFavoritesPut(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		if err := services.AddFavorite(s, log, r); err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func HandlerFavoritesDelete(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		if err := services.RemoveFavorite(s, log, r); err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func HandlerCollectionsGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		writeJSON(w, log, services.GetCollections(s, log, r))
+	}
+}
+
+func HandlerCollectionsPost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		collection, err := services.CreateCollection(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSONStatus(w, log, http.StatusCreated, collection)
+	}
+}
+
+func HandlerCollectionGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		collection, err := services.GetCollection(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, collection)
+	}
+}
+
+func HandlerCollectionPatch(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		collection, err := services.RenameCollection(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, collection)
+	}
+}
+
+func HandlerCollectionDelete(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		if err := services.DeleteCollection(s, log, r); err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func HandlerCollectionQuotePut(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		collection, err := services.AddToCollection(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, collection)
+	}
+}
+
+func HandlerCollectionQuoteDelete(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		collection, err := services.RemoveFromCollection(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, collection)
+	}
+}
+
+func HandlerQuoteLikePost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Like(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, quote)
+	}
+}
+
+func HandlerQuoteLikeDelete(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Unlike(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, quote)
+	}
+}
+
+func HandlerQuoteRatingPost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Rate(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, quote)
+	}
+}
+
+func HandlerQuotesTopGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quotes, err := services.GetTopRated(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, quotes)
+	}
+}
+
+func HandlerModerationQueueGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		writeJSON(w, log, services.GetModerationQueue(s, log, r))
+	}
+}
+
+func HandlerModerationApprovePost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Approve(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, quote)
+	}
+}
+
+func HandlerModerationRejectPost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.Reject(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, quote)
+	}
+}
+
+func HandlerQuoteReportPost(s *storage.JSONStorage, threshold func() int, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		result, err := services.Report(s, threshold(), log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSONStatus(w, log, http.StatusCreated, result)
+	}
+}
+
+func HandlerReportsGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		reports, err := services.GetReports(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, reports)
+	}
+}
+
+func HandlerReportsResolvePost(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		summary, err := services.ResolveReports(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, summary)
+	}
+}
+
+func HandlerTranslationsGet(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		translations, err := services.GetTranslations(s, log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, log, translations)
+	}
+}
+
+func HandlerTranslationPut(s *storage.JSONStorage, filters *filter.Chain, moderation func() bool, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		quote, err := services.SetTranslation(s, filters, services.Moderated(r, moderation()), log, r)
+		if err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("ETag", services.ETag(quote))
+		writeJSONStatus(w, log, updateStatus(quote), quote)
+	}
+}
+
+func HandlerTranslationDelete(s *storage.JSONStorage, log *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.WithContext(r.Context())
+
+		if err := services.DeleteTranslation(s, log, r); err != nil {
+			log.Error("Ошибка обработки запроса", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithContext возвращает логгер, добавляющий к сообщениям идентификатор
+// запроса и идентификатор трассы из ctx, если они есть.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	var args []any
+	if id := RequestID(ctx); id != "" {
+		args = append(args, "request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		args = append(args, "trace_id", sc.TraceID().String())
+	}
+
+	if len(args) == 0 {
+		return l
+	}
+	return l.With(args...)
+}
@@ -1,45 +1,171 @@
 package logger
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"sync"
+	"strings"
 )
 
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+
+	DefaultOutput = "log.log"
+)
+
+type Options struct {
+	// Level минимальный уровень записываемых сообщений.
+	Level slog.Level
+	// Format формат вывода: FormatText (по умолчанию) или FormatJSON.
+	Format string
+	// Outputs приемники логов: OutputStdout, OutputStderr или пути к файлам.
+	// По умолчанию DefaultOutput.
+	Outputs []string
+	// Rotate параметры ротации файловых приемников.
+	Rotate RotateOptions
+}
+
+// Logger обертка над slog.Logger. Методы принимают сообщение и пары
+// ключ-значение, поэтому старые вызовы Info(str) и Error(str) работают
+// без изменений.
 type Logger struct {
-	InfoLog  *log.Logger
-	ErrorLog *log.Logger
-	File     *os.File
-	mu       sync.Mutex
+	slog  *slog.Logger
+	level *slog.LevelVar
+	// File первый файловый приемник, nil если логи пишутся только в консоль.
+	File  *RotatingFile
+	files []*RotatingFile
 }
 
 func NewLogger() (*Logger, error) {
-	file, err := os.OpenFile("log.log", os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
+	return New(Options{Level: slog.LevelInfo, Format: FormatText})
+}
+
+func New(opts Options) (*Logger, error) {
+	outputs := opts.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{DefaultOutput}
+	}
+
+	var writers []io.Writer
+	var files []*RotatingFile
+
+	closeFiles := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+
+	for _, output := range outputs {
+		switch output = strings.TrimSpace(output); output {
+		case OutputStdout:
+			writers = append(writers, os.Stdout)
+		case OutputStderr:
+			writers = append(writers, os.Stderr)
+		default:
+			file, err := OpenRotatingFile(output, opts.Rotate)
+			if err != nil {
+				closeFiles()
+				return nil, err
+			}
+			files = append(files, file)
+			writers = append(writers, file)
+		}
+	}
+
+	l, err := NewWithWriter(io.MultiWriter(writers...), opts)
 	if err != nil {
+		closeFiles()
 		return nil, err
 	}
+	l.files = files
+	if len(files) > 0 {
+		l.File = files[0]
+	}
+
+	return l, nil
+}
+
+// NewWithWriter создает логгер, пишущий в w. Файл при этом не открывается.
+func NewWithWriter(w io.Writer, opts Options) (*Logger, error) {
+	level := new(slog.LevelVar)
+	level.Set(opts.Level)
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch opts.Format {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("Неизвестный формат логов: %s", opts.Format)
+	}
+
+	return &Logger{slog: slog.New(handler), level: level}, nil
+}
+
+// ParseLevel разбирает уровень логирования: debug, info, warn или error.
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return level, fmt.Errorf("Неизвестный уровень логирования: %s", value)
+	}
+	return level, nil
+}
+
+func (l *Logger) SetLevel(level slog.Level) {
+	l.level.Set(level)
+}
+
+func (l *Logger) Level() slog.Level {
+	return l.level.Level()
+}
+
+// With возвращает логгер, добавляющий args к каждому сообщению.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{slog: l.slog.With(args...), level: l.level, File: l.File, files: l.files}
+}
+
+func (l *Logger) Slog() *slog.Logger {
+	return l.slog
+}
 
-	return &Logger{
-		InfoLog:  log.New(file, "INFO\t", log.Ldate|log.Ltime),
-		ErrorLog: log.New(file, "ERROR\t", log.Ldate|log.Ltime),
-		File:     file,
-	}, nil
+func (l *Logger) Debug(msg string, args ...any) {
+	l.slog.Debug(msg, args...)
 }
 
-func (l *Logger) Info(str string) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.InfoLog.Println(str)
+func (l *Logger) Info(msg string, args ...any) {
+	l.slog.Info(msg, args...)
 }
 
-func (l *Logger) Error(str string) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.ErrorLog.Println(str)
+func (l *Logger) Warn(msg string, args ...any) {
+	l.slog.Warn(msg, args...)
+}
+
+func (l *Logger) Error(msg string, args ...any) {
+	l.slog.Error(msg, args...)
+}
+
+// Reopen заново открывает файловые приемники, например после внешней ротации.
+func (l *Logger) Reopen() error {
+	var errs []error
+	for _, file := range l.files {
+		if err := file.Reopen(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.Path(), err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (l *Logger) Close() {
-	if l.File != nil {
-		l.File.Close()
+	for _, file := range l.files {
+		file.Close()
 	}
 }
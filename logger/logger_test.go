@@ -1,15 +1,21 @@
 package logger_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"quotes/logger"
 )
 
 func TestLogger(t *testing.T) {
-	log, err := logger.NewLogger()
+	path := filepath.Join(t.TempDir(), "log.log")
+	log, err := logger.New(logger.Options{Outputs: []string{path}})
 	if err != nil {
 		t.Fatalf("Не удалось создать логгер: %v", err)
 	}
@@ -19,7 +25,7 @@ func TestLogger(t *testing.T) {
 	infoMessage := "Информация..."
 	log.Info(infoMessage)
 
-	content, err := os.ReadFile("log.log")
+	content, err := os.ReadFile(path)
 	if err != nil {
 		t.Fatalf("Не удалось прочитать файл: %v", err)
 	}
@@ -31,7 +37,7 @@ func TestLogger(t *testing.T) {
 	errorMessage := "Ошибка..."
 	log.Error(errorMessage)
 
-	content, err = os.ReadFile("log.log")
+	content, err = os.ReadFile(path)
 	if err != nil {
 		t.Fatalf("Не удалось прочитать файл: %v", err)
 	}
@@ -46,3 +52,138 @@ func TestLogger(t *testing.T) {
 		t.Error("Ожидалась ошибка при повторном закрытии файла")
 	}
 }
+
+func TestLoggerLevelsAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	log, err := logger.NewWithWriter(&buf, logger.Options{Level: slog.LevelWarn, Format: logger.FormatJSON})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	// Тест 1: Сообщения ниже минимального уровня отбрасываются
+	log.Info("пропущено")
+	log.Warn("предупреждение", "id", 7)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Ожидалась 1 строка, получено: %q", buf.String())
+	}
+
+	// Тест 2: JSON-формат с атрибутами
+	var record map[string]any
+	if err = json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Строка лога не является JSON: %v", err)
+	}
+	if record["level"] != "WARN" || record["msg"] != "предупреждение" || record["id"] != float64(7) {
+		t.Errorf("Некорректная запись: %v", record)
+	}
+
+	// Тест 3: Изменение уровня на лету и With
+	buf.Reset()
+	log.SetLevel(slog.LevelDebug)
+	log.With("request_id", "abc").Debug("отладка")
+	if !strings.Contains(buf.String(), `"request_id":"abc"`) {
+		t.Errorf("Ожидался атрибут request_id: %s", buf.String())
+	}
+
+	// Тест 4: Разбор уровней и форматов
+	if level, err := logger.ParseLevel("error"); err != nil || level != slog.LevelError {
+		t.Errorf("Ожидался уровень ERROR, получено: %v, %v", level, err)
+	}
+	if _, err = logger.ParseLevel("verbose"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного уровня")
+	}
+	if _, err = logger.NewWithWriter(&buf, logger.Options{Format: "xml"}); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного формата")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// Тест 1: Дозапись вместо перезаписи
+	if err := os.WriteFile(path, []byte("previous run\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать файл: %v", err)
+	}
+
+	log, err := logger.New(logger.Options{
+		Outputs: []string{path},
+		Rotate:  logger.RotateOptions{MaxSize: 200, MaxBackups: 2, Compress: true},
+	})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+	defer log.Close()
+
+	log.Info("first")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать файл: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "previous run\n") {
+		t.Errorf("Содержимое предыдущего запуска потеряно: %s", content)
+	}
+
+	// Тест 2: Ротация по размеру со сжатием и ограничением количества копий
+	for i := 0; i < 20; i++ {
+		log.Info(strings.Repeat("x", 50))
+	}
+
+	if err = log.File.Wait(); err != nil {
+		t.Fatalf("Фоновое сжатие вернуло ошибку: %v", err)
+	}
+	backups, err := log.File.Backups()
+	if err != nil {
+		t.Fatalf("Backups вернула ошибку: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Errorf("Ожидалось 2 ротированных файла, получено: %v", backups)
+	}
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup, ".gz") {
+			t.Errorf("Ротированный файл не сжат: %s", backup)
+		}
+	}
+
+	// Тест 3: Повторное открытие после внешней ротации
+	if err = os.Rename(path, path+".external"); err != nil {
+		t.Fatalf("Не удалось переименовать файл: %v", err)
+	}
+	if err = log.Reopen(); err != nil {
+		t.Fatalf("Reopen вернула ошибку: %v", err)
+	}
+	log.Info("after reopen")
+
+	content, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать файл: %v", err)
+	}
+	if !strings.Contains(string(content), "after reopen") {
+		t.Errorf("Сообщение после Reopen не найдено: %s", content)
+	}
+
+	// Тест 4: Возраст файла прошлого запуска считается от времени его изменения
+	aged := filepath.Join(dir, "aged.log")
+	if err = os.WriteFile(aged, []byte("old run\n"), 0644); err != nil {
+		t.Fatalf("Не удалось записать файл: %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err = os.Chtimes(aged, old, old); err != nil {
+		t.Fatalf("Не удалось изменить время файла: %v", err)
+	}
+	file, err := logger.OpenRotatingFile(aged, logger.RotateOptions{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile вернула ошибку: %v", err)
+	}
+	if _, err = file.Write([]byte("new run\n")); err != nil {
+		t.Fatalf("Write вернула ошибку: %v", err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatalf("Close вернула ошибку: %v", err)
+	}
+	if backups, err = file.Backups(); err != nil || len(backups) != 1 {
+		t.Errorf("Ожидалась ротация устаревшего файла, получено: %v, %v", backups, err)
+	}
+}
@@ -0,0 +1,289 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RotateOptions struct {
+	// MaxSize размер файла в байтах, после которого он ротируется. 0 - без ограничения.
+	MaxSize int64
+	// MaxAge возраст файла, после которого он ротируется. 0 - без ограничения.
+	MaxAge time.Duration
+	// MaxBackups сколько ротированных файлов хранить. 0 - хранить все.
+	MaxBackups int
+	// Compress сжимать ротированные файлы gzip.
+	Compress bool
+}
+
+const backupTimeFormat = "20060102-150405.000"
+
+// RotatingFile файл логов, открываемый на дозапись и ротируемый по размеру
+// и возрасту. Ротированные файлы получают суффикс с временем ротации и
+// сжимаются в фоне, не блокируя запись.
+type RotatingFile struct {
+	mu   sync.Mutex
+	path string
+	opts RotateOptions
+	file *os.File
+	size int64
+	// startedAt время начала текущего файла, от него считается MaxAge. Для
+	// файла, оставшегося от прошлого запуска, берется время его изменения,
+	// чтобы перезапуски не откладывали ротацию.
+	startedAt time.Time
+
+	// archive упорядочивает фоновое сжатие и удаление старых копий,
+	// background позволяет дождаться их завершения.
+	archive    sync.Mutex
+	background sync.WaitGroup
+	archiveErr error
+}
+
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) Path() string {
+	return f.path
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.startedAt = time.Now()
+	if f.size > 0 {
+		f.startedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.needsRotation(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) needsRotation(next int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.opts.MaxSize > 0 && f.size+next > f.opts.MaxSize {
+		return true
+	}
+	return f.opts.MaxAge > 0 && time.Since(f.startedAt) > f.opts.MaxAge
+}
+
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// rotate вызывается под f.mu.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	backup := f.backupName(time.Now())
+	if err := os.Rename(f.path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.background.Add(1)
+	go func() {
+		defer f.background.Done()
+		f.archive.Lock()
+		defer f.archive.Unlock()
+
+		if err := f.compact(backup); err != nil && f.archiveErr == nil {
+			f.archiveErr = err
+		}
+	}()
+
+	return nil
+}
+
+// compact сжимает ротированный файл и удаляет копии сверх MaxBackups.
+// Копия могла быть удалена предыдущим вызовом, если ротации шли быстрее
+// сжатия. Вызывается под f.archive.
+func (f *RotatingFile) compact(backup string) error {
+	if f.opts.Compress {
+		if err := compressFile(backup); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Не удалось сжать %s: %w", backup, err)
+		}
+	}
+	return f.prune()
+}
+
+// Wait ждет завершения фонового сжатия и удаления старых копий и возвращает
+// первую ошибку, случившуюся с прошлого вызова.
+func (f *RotatingFile) Wait() error {
+	f.background.Wait()
+
+	f.archive.Lock()
+	defer f.archive.Unlock()
+	err := f.archiveErr
+	f.archiveErr = nil
+	return err
+}
+
+// backupName подбирает имя для ротированного файла, не совпадающее с уже
+// существующими, если ротации случились в одну миллисекунду.
+func (f *RotatingFile) backupName(now time.Time) string {
+	for {
+		backup := f.path + "." + now.Format(backupTimeFormat)
+		if !exists(backup) && !exists(backup+".gz") {
+			return backup
+		}
+		now = now.Add(time.Millisecond)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Reopen закрывает и заново открывает файл по тому же пути. Используется
+// после того, как внешний logrotate переименовал файл.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	return f.open()
+}
+
+// Close закрывает файл и дожидается фонового сжатия ротированных копий.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	if f.file == nil {
+		f.mu.Unlock()
+		return os.ErrClosed
+	}
+	err := f.file.Close()
+	f.file = nil
+	f.mu.Unlock()
+
+	return errors.Join(err, f.Wait())
+}
+
+// Backups возвращает ротированные файлы от старых к новым.
+func (f *RotatingFile) Backups() ([]string, error) {
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	backups := matches[:0]
+	for _, match := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(match, f.path+"."), ".gz")
+		if _, err := time.Parse(backupTimeFormat, suffix); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+
+	return backups, nil
+}
+
+// prune удаляет самые старые ротированные файлы сверх MaxBackups.
+func (f *RotatingFile) prune() error {
+	if f.opts.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > f.opts.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
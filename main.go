@@ -2,100 +2,432 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"quotes/audit"
+	"quotes/auth"
+	"quotes/config"
+	"quotes/filter"
 	"quotes/handlers"
 	"quotes/logger"
+	"quotes/metrics"
+	"quotes/middleware"
+	"quotes/ratelimit"
+	"quotes/services"
 	"quotes/storage"
+	"quotes/tracing"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
-func WaitClose(log *logger.Logger) chan struct{} {
+// version задается при сборке: go build -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
+// WaitClose возвращает канал, который закрывается при получении SIGINT или
+// SIGTERM, а в интерактивном режиме еще и по нажатию Enter.
+func WaitClose(log *logger.Logger, interactive bool) chan struct{} {
 	stop := make(chan struct{})
 
-	go func() {
-		scanner := bufio.NewScanner(os.Stdin)
+	var once sync.Once
+	closeStop := func(reason string, args ...any) {
+		once.Do(func() {
+			log.Info(reason, args...)
+			close(stop)
+		})
+	}
 
-		fmt.Println("Нажмите Enter для завершения работы программы")
-		scanner.Scan()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
-		log.Info("Получен сигнал завершения работы")
-		close(stop)
+	go func() {
+		sig := <-signals
+		closeStop("Получен сигнал завершения работы", "signal", sig.String())
 	}()
 
+	if interactive {
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+
+			fmt.Println("Нажмите Enter для завершения работы программы")
+			if scanner.Scan() {
+				closeStop("Получен сигнал завершения работы из консоли")
+			}
+		}()
+	}
+
 	return stop
 }
 
-func loadEnv() (map[string]string, error) {
-	env := make(map[string]string)
+// WatchSignals перечитывает конфигурацию при получении SIGHUP и заново
+// открывает файлы логов при получении SIGUSR1, пока не будет закрыт stop.
+func WatchSignals(reloader *config.Reloader, log *logger.Logger, stop <-chan struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case sig := <-signals:
+			switch sig {
+			case syscall.SIGHUP:
+				log.Info("Получен SIGHUP, перечитывание конфигурации")
+				if _, err := services.ReloadConfig(reloader, log); err != nil {
+					log.Error("Ошибка перезагрузки конфигурации", "error", err)
+				}
+			case syscall.SIGUSR1:
+				if err := log.Reopen(); err != nil {
+					log.Error("Не удалось заново открыть файлы логов", "error", err)
+				} else {
+					log.Info("Получен SIGUSR1, файлы логов открыты заново")
+				}
+			}
+		}
+	}
+}
 
-	file, err := os.Open(".env")
+// runAudit выполняет команду "audit verify": проверяет цепочку хешей
+// журнала аудита и возвращает код завершения.
+func runAudit(args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Println("Использование: quotes audit verify [флаги конфигурации]")
+		return 2
+	}
+
+	cfg, err := config.Load(args[1:])
+	if err != nil && !errors.Is(err, config.ErrPrintConfig) {
+		fmt.Printf("Ошибка загрузки конфигурации: %v\n", err)
+		return 2
+	}
+
+	count, err := audit.Verify(cfg.AuditPath)
 	if err != nil {
-		return nil, fmt.Errorf("Не удалось открыть .env файл: %w", err)
+		fmt.Printf("Журнал аудита %s поврежден после %d записей: %v\n", cfg.AuditPath, count, err)
+		return 1
+	}
+
+	fmt.Printf("Журнал аудита %s в порядке, записей: %d\n", cfg.AuditPath, count)
+	return 0
+}
+
+// runKeys выполняет команды управления API-ключами и возвращает код завершения.
+func runKeys(args []string) int {
+	usage := func() int {
+		fmt.Println("Использование: quotes keys create <имя> [роль] | list | revoke <id> [флаги конфигурации]")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+
+	action, args := args[0], args[1:]
+	var target string
+	if action == "create" || action == "revoke" {
+		if len(args) == 0 {
+			return usage()
+		}
+		target, args = args[0], args[1:]
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
+	role := auth.RoleContributor
+	if action == "create" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		parsed, err := auth.ParseRole(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		role, args = parsed, args[1:]
+	}
+
+	cfg, err := config.Load(args)
+	if err != nil && !errors.Is(err, config.ErrPrintConfig) {
+		fmt.Printf("Ошибка загрузки конфигурации: %v\n", err)
+		return 2
+	}
+
+	keys, err := auth.OpenKeyStore(cfg.APIKeysPath)
+	if err != nil {
+		fmt.Printf("Не удалось открыть файл ключей %s: %v\n", cfg.APIKeysPath, err)
+		return 1
+	}
 
-		parts := strings.Split(line, "=")
-		env[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	switch action {
+	case "create":
+		key, token, err := keys.Create(target, role)
+		if err != nil {
+			fmt.Printf("Не удалось создать ключ: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Создан ключ %s (%s, %s). Сохраните его, повторно он показан не будет:\n%s\n", key.ID, key.Name, key.Role, token)
+	case "list":
+		for _, key := range keys.List() {
+			state, lastUsed := "активен", "никогда"
+			if !key.Active() {
+				state = "отозван"
+			}
+			if key.LastUsedAt != nil {
+				lastUsed = key.LastUsedAt.Local().Format(time.DateTime)
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\tсоздан %s\tиспользован %s\n",
+				key.ID, key.Name, key.Role, state, key.CreatedAt.Local().Format(time.DateTime), lastUsed)
+		}
+	case "revoke":
+		if _, err := keys.Revoke(target); err != nil {
+			fmt.Printf("Не удалось отозвать ключ: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Ключ %s отозван\n", target)
+	default:
+		return usage()
 	}
-	return env, nil
+
+	return 0
 }
 
 func main() {
-	var env map[string]string
-	env, err := loadEnv()
+	startedAt := time.Now()
+
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAudit(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		os.Exit(runKeys(os.Args[2:]))
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, config.ErrPrintConfig) {
+		cfg.Print(os.Stdout)
+		return
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		fmt.Println(err)
-		env["JSONPATH"] = "./storage/quotes.json"
-		env["PORT"] = "8080"
+		fmt.Printf("Ошибка загрузки конфигурации: %v\n", err)
+		os.Exit(2)
 	}
 
-	log, err := logger.NewLogger()
+	reloader := config.NewReloader(cfg, os.Args[1:])
+
+	log, err := logger.New(cfg.LoggerOptions())
 	if err != nil {
 		fmt.Printf("Ошибка инициализации логгера: %v\n", err)
 		return
 	}
 	defer log.Close()
 
-	log.Info("Запуск сервера")
+	reloader.OnChange(func(cfg *config.Config) {
+		log.SetLevel(cfg.LogLevel)
+	})
+
+	log.Info("Запуск сервера", "port", cfg.Port, "version", version)
 
-	storage, err := storage.CreateJSONStorage(env["JSONPATH"], log)
+	shutdownTracing, err := tracing.Setup(cfg.TracingOptions(version))
 	if err != nil {
-		log.Error(fmt.Sprintf("Не удалось инициализировавть хранилище: %v", err))
+		log.Error("Не удалось настроить трассировку", "error", err)
 		return
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("Не удалось отправить оставшиеся spans", "error", err)
+		}
+	}()
+
+	storage, err := storage.CreateJSONStorage(cfg.JSONPath, log)
+	if err != nil {
+		log.Error("Не удалось инициализировать хранилище", "path", cfg.JSONPath, "error", err)
+		return
+	}
+
+	auditLog, err := audit.Open(cfg.AuditPath)
+	if err != nil {
+		log.Error("Не удалось открыть журнал аудита", "path", cfg.AuditPath, "error", err)
+		return
+	}
+	defer auditLog.Close()
+
+	services.AuditChanges(storage, auditLog, log)
+
+	keys, err := auth.OpenKeyStore(cfg.APIKeysPath)
+	if err != nil {
+		log.Error("Не удалось открыть файл API-ключей", "path", cfg.APIKeysPath, "error", err)
+		return
+	}
+	defer func() {
+		if err := keys.Save(); err != nil {
+			log.Error("Не удалось сохранить API-ключи", "path", cfg.APIKeysPath, "error", err)
+		}
+	}()
+
+	filters, err := filter.Load(cfg.FilterPath)
+	if err != nil {
+		log.Error("Не удалось загрузить правила фильтра", "path", cfg.FilterPath, "error", err)
+		return
+	}
+	log.Info("Фильтр содержимого загружен", "path", cfg.FilterPath, "rules", filters.Len())
+	reloader.OnReload(func(cfg *config.Config) {
+		if err := filters.Reload(cfg.FilterPath); err != nil {
+			log.Error("Не удалось перечитать правила фильтра, оставлены прежние", "path", cfg.FilterPath, "error", err)
+			return
+		}
+		log.Info("Правила фильтра перечитаны", "path", cfg.FilterPath, "rules", filters.Len())
+	})
+
+	m := metrics.New()
+	m.ObserveStorage(storage)
+
+	limiter := ratelimit.New(cfg.RateLimits())
+	reloader.OnChange(func(cfg *config.Config) {
+		limiter.SetLimits(cfg.RateLimits())
+	})
 
 	rand.Seed(time.Now().UnixNano())
 
-	stop := WaitClose(log)
+	stop := WaitClose(log, cfg.Interactive)
+
+	go WatchSignals(reloader, log, stop)
+	go services.RunAutosave(storage, keys, cfg.JSONPath, log, func() time.Duration {
+		return reloader.Current().SaveInterval
+	}, stop)
+	go services.RunTrashPurge(storage, log, func() time.Duration {
+		return reloader.Current().TrashRetention
+	}, time.Hour, stop)
 
 	defer func() {
-		if err = storage.Save(env["JSONPATH"], log); err != nil {
-			log.Error(fmt.Sprintf("Не удалось сохранить данные: %v", err))
+		if err = storage.Save(context.Background(), cfg.JSONPath, log); err != nil {
+			log.Error("Не удалось сохранить данные", "path", cfg.JSONPath, "error", err)
 		}
 	}()
 
+	health := services.Health{Version: version, StartedAt: startedAt, JSONPath: cfg.JSONPath}
+
+	moderationEnabled := func() bool {
+		return reloader.Current().Moderation
+	}
+	reportThreshold := func() int {
+		return reloader.Current().ReportThreshold
+	}
+
 	r := mux.NewRouter()
-	r.HandleFunc("/quotes", handlers.HandlerQuotesPost(storage, log)).Methods("POST")
+	var tokens *auth.JWTVerifier
+	if cfg.JWKSPath != "" {
+		if tokens, err = auth.NewJWTVerifier(cfg.JWTOptions()); err != nil {
+			log.Error("Не удалось загрузить JWKS", "path", cfg.JWKSPath, "error", err)
+			return
+		}
+	}
+
+	r.Use(
+		middleware.Tracing,
+		middleware.Metrics(m),
+		middleware.Authenticate(keys, tokens, storage, limiter, log),
+		middleware.RateLimit(limiter, log),
+		middleware.RequireAuth(middleware.MutatingOrAdmin),
+	)
+	r.NotFoundHandler = middleware.Metrics(m)(http.NotFoundHandler())
+	r.MethodNotAllowedHandler = middleware.Metrics(m)(middleware.MethodNotAllowed())
+	r.Handle("/metrics", m.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", handlers.HandlerHealthz(log)).Methods("GET")
+	r.HandleFunc("/readyz", handlers.HandlerReadyz(storage, health, log)).Methods("GET")
+	r.HandleFunc("/status", handlers.HandlerStatusGet(storage, health, log)).Methods("GET")
+	r.Handle("/quotes", middleware.Require(auth.PermCreate)(handlers.HandlerQuotesPost(storage, filters, moderationEnabled, log))).Methods("POST")
 	r.HandleFunc("/quotes", handlers.HandlerQuotesGet(storage, log)).Methods("GET")
 	r.HandleFunc("/quotes/random", handlers.HandlerQuotesRandomGet(storage, log)).Methods("GET")
-	r.HandleFunc("/quotes/{id}", handlers.HandlerQuotesDelete(storage, log)).Methods("DELETE")
+	r.HandleFunc("/quotes/top", handlers.HandlerQuotesTopGet(storage, log)).Methods("GET")
+	r.HandleFunc("/quotes/{id}", handlers.HandlerQuoteGet(storage, log)).Methods("GET")
+	r.Handle("/quotes/{id}", middleware.Require(auth.PermUpdate, auth.PermUpdateOwn)(handlers.HandlerQuotesPut(storage, filters, moderationEnabled, log))).Methods("PUT")
+	r.Handle("/quotes/{id}", middleware.Require(auth.PermUpdate, auth.PermUpdateOwn)(handlers.HandlerQuotesPatch(storage, filters, moderationEnabled, log))).Methods("PATCH")
+	r.Handle("/quotes/{id}", middleware.Require(auth.PermDelete)(handlers.HandlerQuotesDelete(storage, log))).Methods("DELETE")
+	r.HandleFunc("/quotes/{id}/like", handlers.HandlerQuoteLikePost(storage, log)).Methods("POST")
+	r.HandleFunc("/quotes/{id}/like", handlers.HandlerQuoteLikeDelete(storage, log)).Methods("DELETE")
+	r.HandleFunc("/quotes/{id}/rating", handlers.HandlerQuoteRatingPost(storage, log)).Methods("POST")
+	r.HandleFunc("/quotes/{id}/report", handlers.HandlerQuoteReportPost(storage, reportThreshold, log)).Methods("POST")
+	r.HandleFunc("/quotes/{id}/translations", handlers.HandlerTranslationsGet(storage, log)).Methods("GET")
+	r.Handle("/quotes/{id}/translations/{lang}", middleware.Require(auth.PermUpdate, auth.PermUpdateOwn)(handlers.HandlerTranslationPut(storage, filters, moderationEnabled, log))).Methods("PUT")
+	r.Handle("/quotes/{id}/translations/{lang}", middleware.Require(auth.PermUpdate, auth.PermUpdateOwn)(handlers.HandlerTranslationDelete(storage, log))).Methods("DELETE")
+	r.HandleFunc("/quotes/{id}/history", handlers.HandlerQuotesHistoryGet(storage, log)).Methods("GET")
+	r.Handle("/quotes/{id}/revert/{rev}", middleware.Require(auth.PermRestore)(handlers.HandlerQuotesRevertPost(storage, log))).Methods("POST")
+	r.HandleFunc("/authors/suggest", handlers.HandlerAuthorsSuggest(storage, log)).Methods("GET")
+	r.HandleFunc("/stats", handlers.HandlerStatsGet(storage, log)).Methods("GET")
+	r.Handle("/trash", middleware.Require(auth.PermRestore)(handlers.HandlerTrashGet(storage, log))).Methods("GET")
+	r.Handle("/trash/{id}/restore", middleware.Require(auth.PermRestore)(handlers.HandlerTrashRestorePost(storage, log))).Methods("POST")
+
+	r.HandleFunc("/auth/register", handlers.HandlerRegisterPost(storage, log)).Methods("POST")
+	r.HandleFunc("/auth/login", handlers.HandlerLoginPost(storage, cfg.SessionTTL, log)).Methods("POST")
+	r.Handle("/auth/logout", middleware.RequireUser(handlers.HandlerLogoutPost(storage, log))).Methods("POST")
+
+	me := r.PathPrefix("/me").Subrouter()
+	me.Use(middleware.RequireUser)
+	me.HandleFunc("", handlers.HandlerMeGet(storage, log)).Methods("GET")
+	me.HandleFunc("/favorites", handlers.HandlerFavoritesGet(storage, log)).Methods("GET")
+	me.HandleFunc("/favorites/{id}", handlers.HandlerFavoritesPut(storage, log)).Methods("PUT")
+	me.HandleFunc("/favorites/{id}", handlers.HandlerFavoritesDelete(storage, log)).Methods("DELETE")
+	me.HandleFunc("/collections", handlers.HandlerCollectionsGet(storage, log)).Methods("GET")
+	me.HandleFunc("/collections", handlers.HandlerCollectionsPost(storage, log)).Methods("POST")
+	me.HandleFunc("/collections/{cid}", handlers.HandlerCollectionGet(storage, log)).Methods("GET")
+	me.HandleFunc("/collections/{cid}", handlers.HandlerCollectionPatch(storage, log)).Methods("PATCH")
+	me.HandleFunc("/collections/{cid}", handlers.HandlerCollectionDelete(storage, log)).Methods("DELETE")
+	me.HandleFunc("/collections/{cid}/quotes/{id}", handlers.HandlerCollectionQuotePut(storage, log)).Methods("PUT")
+	me.HandleFunc("/collections/{cid}/quotes/{id}", handlers.HandlerCollectionQuoteDelete(storage, log)).Methods("DELETE")
 
+	moderation := r.PathPrefix("/moderation").Subrouter()
+	moderation.Use(middleware.Require(auth.PermModerate))
+	moderation.HandleFunc("/queue", handlers.HandlerModerationQueueGet(storage, log)).Methods("GET")
+	moderation.HandleFunc("/{id}/approve", handlers.HandlerModerationApprovePost(storage, log)).Methods("POST")
+	moderation.HandleFunc("/{id}/reject", handlers.HandlerModerationRejectPost(storage, log)).Methods("POST")
+
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.Require(auth.PermAdmin))
+	admin.HandleFunc("/audit", handlers.HandlerAuditGet(auditLog, log)).Methods("GET")
+	admin.HandleFunc("/keys", handlers.HandlerKeysGet(keys, log)).Methods("GET")
+	admin.HandleFunc("/keys", handlers.HandlerKeysPost(keys, log)).Methods("POST")
+	admin.HandleFunc("/keys/{id}", handlers.HandlerKeysDelete(keys, log)).Methods("DELETE")
+	admin.HandleFunc("/reports", handlers.HandlerReportsGet(storage, log)).Methods("GET")
+	admin.HandleFunc("/reports/{id}/resolve", handlers.HandlerReportsResolvePost(storage, log)).Methods("POST")
+	admin.HandleFunc("/config", handlers.HandlerConfigGet(reloader, log)).Methods("GET")
+	admin.HandleFunc("/config/reload", handlers.HandlerConfigReloadPost(reloader, log)).Methods("POST")
+
+	server := &http.Server{
+		Addr:    ":" + strconv.Itoa(cfg.Port),
+		Handler: middleware.RequestID(middleware.AccessLog(log)(r)),
+	}
+
+	serverErr := make(chan error, 1)
 	go func() {
-		if err := http.ListenAndServe(":"+env["PORT"], r); err != nil {
-			log.Error(fmt.Sprintf("Ошибка при запуске сервера: %v", err))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
 	}()
 
-	<-stop
+	select {
+	case <-stop:
+	case err := <-serverErr:
+		log.Error("Ошибка при запуске сервера", "error", err)
+		return
+	}
+
+	log.Info("Остановка сервера, ожидание завершения запросов")
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloader.Current().ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error("Не удалось дождаться завершения запросов", "error", err)
+	}
+
 	log.Info("Завершение работы программы")
 }
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"net/http"
+	"quotes/storage"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "quotes"
+
+// Metrics метрики сервиса в формате Prometheus. Каждый экземпляр использует
+// собственный реестр, поэтому в тестах их можно создавать несколько.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	storageDuration *prometheus.HistogramVec
+	saveDuration    prometheus.Histogram
+	saveFailures    prometheus.Counter
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Количество HTTP-запросов по маршруту, методу и статусу.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Длительность обработки HTTP-запросов.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Длительность операций хранилища.",
+			Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+		}, []string{"operation"}),
+		saveDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_save_duration_seconds",
+			Help:      "Длительность сохранения хранилища на диск.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		saveFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "storage_save_failures_total",
+			Help:      "Количество неудачных сохранений хранилища.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.storageDuration,
+		m.saveDuration,
+		m.saveFailures,
+	)
+
+	return m
+}
+
+// Handler отдает метрики в текстовом формате Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest учитывает обработанный HTTP-запрос. route - шаблон
+// маршрута mux, а не фактический путь, чтобы число серий было ограничено.
+func (m *Metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	labels := prometheus.Labels{"route": route, "method": method, "status": strconv.Itoa(status)}
+	m.requests.With(labels).Inc()
+	m.requestDuration.With(labels).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObserveOperation(operation string, duration time.Duration) {
+	m.storageDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObserveSave(duration time.Duration, err error) {
+	m.saveDuration.Observe(duration.Seconds())
+	if err != nil {
+		m.saveFailures.Inc()
+	}
+}
+
+// ObserveStorage подписывается на операции хранилища и добавляет метрики
+// количества цитат и авторов, которые считаются в момент опроса.
+func (m *Metrics) ObserveStorage(s *storage.JSONStorage) {
+	s.SetObserver(m)
+
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "quotes",
+			Help:      "Количество цитат, не считая удаленных.",
+		}, func() float64 {
+			quotes, _ := s.Counts()
+			return float64(quotes)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "authors",
+			Help:      "Количество различных авторов.",
+		}, func() float64 {
+			_, authors := s.Counts()
+			return float64(authors)
+		}),
+	)
+}
@@ -0,0 +1,88 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"quotes/logger"
+	"quotes/metrics"
+	"quotes/middleware"
+	"quotes/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func scrape(m *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	s, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось создать хранилище: %v", err)
+	}
+
+	m := metrics.New()
+	m.ObserveStorage(s)
+
+	r := mux.NewRouter()
+	r.Use(middleware.Metrics(m))
+	r.NotFoundHandler = middleware.Metrics(m)(http.NotFoundHandler())
+	r.MethodNotAllowedHandler = middleware.Metrics(m)(middleware.MethodNotAllowed())
+	r.HandleFunc("/quotes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}).Methods("GET")
+
+	s.Add(context.Background(), storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "")
+	s.Add(context.Background(), storage.Quote{Quote: "Quote 2", Author: "Author 2"}, "")
+
+	for _, path := range []string{"/quotes/1", "/quotes/2", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/quotes/1", nil))
+	if saved, err := s.SaveChanges(context.Background(), path, log); !saved || err != nil {
+		t.Fatalf("Ожидалось сохранение изменений, получено: %v, %v", saved, err)
+	}
+	m.ObserveSave(time.Millisecond, errors.New("disk full"))
+
+	body := scrape(m)
+
+	// Тест 1: Запросы учитываются по шаблону маршрута
+	expected := []string{
+		`quotes_http_requests_total{method="GET",route="/quotes/{id}",status="418"} 2`,
+		`quotes_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`quotes_http_requests_total{method="POST",route="unmatched",status="405"} 1`,
+		`quotes_http_request_duration_seconds_count{method="GET",route="/quotes/{id}",status="418"} 2`,
+	}
+
+	// Тест 2: Операции хранилища, количество цитат и ошибки сохранения
+	expected = append(expected,
+		`quotes_storage_operation_duration_seconds_count{operation="add"} 2`,
+		`quotes_quotes 2`,
+		`quotes_authors 2`,
+		`quotes_storage_save_duration_seconds_count 2`,
+		`quotes_storage_save_failures_total 1`,
+		`go_goroutines`,
+	)
+
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Ожидалась строка %q в метриках:\n%s", line, body)
+		}
+	}
+}
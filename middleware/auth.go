@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"quotes/auth"
+	"quotes/logger"
+	"quotes/ratelimit"
+	"quotes/storage"
+	"regexp"
+	"strings"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// credentials возвращает API-ключ или JWT из заголовка X-API-Key или
+// Authorization: Bearer.
+func credentials(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get(APIKeyHeader)); key != "" {
+		return key
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// Authenticate проверяет API-ключ, токен сессии пользователя или, если
+// tokens не nil, JWT и кладет клиента в контекст запроса. Запрос без учетных
+// данных проходит дальше анонимным, с неверными отклоняется с 401.
+//
+// Неудачная проверка учитывается в limiter (если он не nil) как изменяющий
+// запрос анонимного клиента с этого адреса. Когда лимит адреса исчерпан,
+// учетные данные не проверяются и запрос получает 429, поэтому подбирать
+// ключи и токены не быстрее RATE_WRITE_*.
+func Authenticate(keys *auth.KeyStore, tokens *auth.JWTVerifier, users *storage.JSONStorage, limiter *ratelimit.Limiter, log *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := credentials(r)
+			if raw == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := "ip:" + ClientIP(r)
+			if limiter != nil {
+				if decision := limiter.Check(client, ratelimit.Write); !decision.Allowed {
+					log.WithContext(r.Context()).Warn("Превышен лимит неудачных проверок учетных данных",
+						"client", client, "method", r.Method, "path", r.URL.Path)
+					tooManyRequests(w, decision)
+					return
+				}
+			}
+
+			principal, err := authenticate(r.Context(), keys, tokens, users, raw)
+			if err != nil {
+				log.WithContext(r.Context()).Warn("Отклонен запрос с неверными учетными данными",
+					"method", r.Method, "path", r.URL.Path, "client_ip", ClientIP(r), "error", err)
+				if limiter != nil {
+					limiter.Allow(client, ratelimit.Write)
+				}
+				unauthorized(w, "Недействительные учетные данные")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// authenticate различает API-ключи и токены сессий по префиксу, остальное
+// считает JWT.
+func authenticate(ctx context.Context, keys *auth.KeyStore, tokens *auth.JWTVerifier, users *storage.JSONStorage, raw string) (auth.Principal, error) {
+	if hash, ok := auth.SessionHash(raw); ok && users != nil {
+		user, err := users.Session(ctx, hash)
+		if err != nil {
+			return auth.Principal{}, err
+		}
+		return auth.Principal{
+			Subject:   user.Username,
+			Role:      auth.RoleReader,
+			Method:    auth.MethodSession,
+			UserID:    user.ID,
+			SessionID: hash,
+		}, nil
+	}
+	if strings.HasPrefix(raw, auth.KeyPrefix) || tokens == nil {
+		key, err := keys.Authenticate(raw)
+		if err != nil {
+			return auth.Principal{}, err
+		}
+		return auth.Principal{Subject: key.Name, Role: key.Role, Method: auth.MethodAPIKey, KeyID: key.ID}, nil
+	}
+
+	return tokens.Verify(raw)
+}
+
+// RequireAuth отклоняет с 401 анонимные запросы, для которых required
+// возвращает true.
+func RequireAuth(required func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := auth.FromContext(r.Context()); !ok && required(r) {
+				unauthorized(w, "Требуется аутентификация")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// publicPaths изменяющие запросы, доступные анонимно.
+var publicPaths = map[string]bool{
+	"/auth/register": true,
+	"/auth/login":    true,
+}
+
+// reportPath жалоба на цитату: читатели могут жаловаться без ключа, а
+// повторные жалобы с одного адреса отклоняются, см. services.Report.
+var reportPath = regexp.MustCompile(`^/quotes/[^/]+/report$`)
+
+// MutatingOrAdmin истинна для изменяющих запросов, кроме регистрации, входа
+// и жалоб на цитаты, и для всех запросов к /admin/.
+func MutatingOrAdmin(r *http.Request) bool {
+	if publicPaths[r.URL.Path] || r.Method == http.MethodPost && reportPath.MatchString(r.URL.Path) {
+		return false
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return strings.HasPrefix(r.URL.Path, "/admin/")
+	}
+	return true
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="quotes"`)
+	http.Error(w, message, http.StatusUnauthorized)
+}
+
+// RequireUser пропускает только запросы пользователей, вошедших по имени и
+// паролю, остальным отвечает 401.
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if principal, ok := auth.FromContext(r.Context()); !ok || principal.UserID == 0 {
+			unauthorized(w, "Требуется вход пользователя")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Require пропускает запрос, только если у клиента есть хотя бы одно из
+// прав. Анонимному клиенту без права отвечает 401, остальным - 403 с причиной.
+func Require(required ...auth.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := auth.Authorize(r.Context(), required...)
+			if err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if _, ok := auth.FromContext(r.Context()); !ok {
+				unauthorized(w, "Требуется аутентификация")
+				return
+			}
+			var forbidden *auth.ForbiddenError
+			errors.As(err, &forbidden)
+			http.Error(w, forbidden.Reason, http.StatusForbidden)
+		})
+	}
+}
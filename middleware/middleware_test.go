@@ -0,0 +1,237 @@
+package middleware_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"quotes/auth"
+	"quotes/logger"
+	"quotes/middleware"
+	"quotes/ratelimit"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.NewWithWriter(&buf, logger.Options{Format: logger.FormatJSON})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	var seenID string
+	handler := middleware.RequestID(middleware.AccessLog(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenID = logger.RequestID(r.Context())
+		log.WithContext(r.Context()).Info("внутри сервиса")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	})))
+
+	// Тест 1: Переданный идентификатор запроса сохраняется
+	req := httptest.NewRequest(http.MethodGet, "/quotes", nil)
+	req.Header.Set(middleware.RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if seenID != "abc-123" || rec.Header().Get(middleware.RequestIDHeader) != "abc-123" {
+		t.Errorf("Ожидался request ID abc-123, получено: %q, %q", seenID, rec.Header().Get(middleware.RequestIDHeader))
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Ожидалось 2 строки лога, получено: %s", buf.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, `"request_id":"abc-123"`) {
+			t.Errorf("В строке лога нет request_id: %s", line)
+		}
+	}
+	for _, expected := range []string{`"method":"GET"`, `"path":"/quotes"`, `"status":418`, `"bytes":5`, `"client_ip":"192.0.2.1"`} {
+		if !strings.Contains(lines[1], expected) {
+			t.Errorf("В журнале доступа нет %s: %s", expected, lines[1])
+		}
+	}
+
+	// Тест 2: Некорректный идентификатор заменяется новым
+	req = httptest.NewRequest(http.MethodGet, "/quotes", nil)
+	req.Header.Set(middleware.RequestIDHeader, "bad id\n")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if id := rec.Header().Get(middleware.RequestIDHeader); id == "" || id == "bad id\n" {
+		t.Errorf("Ожидался новый request ID, получено: %q", id)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	keys, err := auth.OpenKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatalf("Не удалось открыть хранилище ключей: %v", err)
+	}
+	_, token, err := keys.Create("deploy", auth.RoleContributor)
+	if err != nil {
+		t.Fatalf("Не удалось создать ключ: %v", err)
+	}
+
+	var subject string
+	handler := middleware.Authenticate(keys, nil, nil, nil, log)(middleware.RequireAuth(middleware.MutatingOrAdmin)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := auth.FromContext(r.Context())
+			subject = principal.Subject
+		}),
+	))
+
+	tests := []struct {
+		method, path, header, value string
+		status                      int
+		subject                     string
+	}{
+		// Тест 1: Чтение доступно без ключа
+		{http.MethodGet, "/quotes", "", "", http.StatusOK, ""},
+		// Тест 2: Изменение и администрирование без ключа запрещены
+		{http.MethodPost, "/quotes", "", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/admin/keys", "", "", http.StatusUnauthorized, ""},
+		{http.MethodDelete, "/quotes/1/report", "", "", http.StatusUnauthorized, ""},
+		// Тест 3: Жалоба на цитату доступна без ключа
+		{http.MethodPost, "/quotes/1/report", "", "", http.StatusOK, ""},
+		// Тест 4: Ключ принимается из X-API-Key и Authorization: Bearer
+		{http.MethodDelete, "/quotes/1", middleware.APIKeyHeader, token, http.StatusOK, "deploy"},
+		{http.MethodPost, "/quotes", "Authorization", "Bearer " + token, http.StatusOK, "deploy"},
+		// Тест 5: Неверный ключ отклоняется даже для чтения
+		{http.MethodGet, "/quotes", middleware.APIKeyHeader, "qk_bad_key", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		subject = ""
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status || subject != tt.subject {
+			t.Errorf("%s %s: ожидалось %d %q, получено %d %q", tt.method, tt.path, tt.status, tt.subject, rec.Code, subject)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s: нет заголовка WWW-Authenticate", tt.method, tt.path)
+		}
+	}
+
+	// Тест 6: Подбор ключей с одного адреса упирается в лимит изменений
+	limiter := ratelimit.New(ratelimit.Limits{Write: ratelimit.Limit{Rate: 0.01, Burst: 3}})
+	limited := middleware.Authenticate(keys, nil, nil, limiter, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/quotes", nil)
+		req.Header.Set(middleware.APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := serve(token); code != http.StatusOK {
+		t.Errorf("Верный ключ не должен расходовать лимит, получено: %d", code)
+	}
+	for i := 0; i < 3; i++ {
+		if code := serve("qk_bad_key"); code != http.StatusUnauthorized {
+			t.Errorf("Попытка %d: ожидался 401, получено: %d", i+1, code)
+		}
+	}
+	if code := serve("qk_bad_key"); code != http.StatusTooManyRequests {
+		t.Errorf("Ожидался 429 после исчерпания лимита, получено: %d", code)
+	}
+	if code := serve(token); code != http.StatusTooManyRequests {
+		t.Errorf("Ключи не должны проверяться с исчерпавшего лимит адреса, получено: %d", code)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	handler := middleware.Require(auth.PermDelete)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		principal *auth.Principal
+		status    int
+	}{
+		// Тест 1: Анонимному клиенту предлагается аутентифицироваться
+		{nil, http.StatusUnauthorized},
+		// Тест 2: Роли без права отвечается 403 с причиной
+		{&auth.Principal{Subject: "alice", Role: auth.RoleContributor}, http.StatusForbidden},
+		// Тест 3: Роль с правом проходит
+		{&auth.Principal{Subject: "carol", Role: auth.RoleEditor}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodDelete, "/quotes/1", nil)
+		if tt.principal != nil {
+			req = req.WithContext(auth.ContextWithPrincipal(req.Context(), *tt.principal))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%+v: ожидался статус %d, получено %d", tt.principal, tt.status, rec.Code)
+		}
+		if rec.Code == http.StatusForbidden && !strings.Contains(rec.Body.String(), string(auth.PermDelete)) {
+			t.Errorf("Ожидалась причина отказа, получено: %s", rec.Body.String())
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	limiter := ratelimit.New(ratelimit.Limits{
+		Read:  ratelimit.Limit{Rate: 1, Burst: 1},
+		Write: ratelimit.Limit{Rate: 1, Burst: 1},
+	})
+	handler := middleware.RateLimit(limiter, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(method, path string, principal *auth.Principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if principal != nil {
+			req = req.WithContext(auth.ContextWithPrincipal(req.Context(), *principal))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Тест 1: Разрешенный запрос получает заголовки RateLimit-*
+	rec := serve(http.MethodGet, "/quotes", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "1" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Неожиданный ответ: %d, %v", rec.Code, rec.Header())
+	}
+
+	// Тест 2: Превышение лимита дает 429 и Retry-After
+	rec = serve(http.MethodGet, "/quotes", nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Ожидался 429 с Retry-After, получено: %d, %v", rec.Code, rec.Header())
+	}
+
+	// Тест 3: Запросы на изменение учитываются отдельно
+	if rec = serve(http.MethodPost, "/quotes", nil); rec.Code != http.StatusOK {
+		t.Errorf("Запрос на изменение отклонен: %d", rec.Code)
+	}
+
+	// Тест 4: Клиент с API-ключом учитывается по ключу, а не по адресу
+	principal := &auth.Principal{Subject: "deploy", Role: auth.RoleContributor, Method: auth.MethodAPIKey, KeyID: "k1"}
+	if rec = serve(http.MethodGet, "/quotes", principal); rec.Code != http.StatusOK {
+		t.Errorf("Запрос с ключом отклонен: %d", rec.Code)
+	}
+
+	// Тест 5: Проверки состояния не ограничиваются
+	for i := 0; i < 3; i++ {
+		if rec = serve(http.MethodGet, "/healthz", nil); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("/healthz ограничен: %d", rec.Code)
+		}
+	}
+}
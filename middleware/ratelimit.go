@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"quotes/auth"
+	"quotes/logger"
+	"quotes/ratelimit"
+	"strconv"
+	"time"
+)
+
+// rateLimitExempt маршруты, которые не ограничиваются: их опрашивают
+// балансировщики и Prometheus.
+var rateLimitExempt = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// RateLimit ограничивает частоту запросов и суточную квоту каждого клиента
+// отдельно для чтения и изменения. Клиент определяется по API-ключу,
+// пользователю или субъекту JWT, а для анонимных запросов по адресу, поэтому
+// подключается после Authenticate. Состояние ограничения возвращается в заголовках
+// RateLimit-*, отклоненный запрос получает 429 и Retry-After.
+func RateLimit(limiter *ratelimit.Limiter, log *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rateLimitExempt[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			class := ratelimit.Read
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				class = ratelimit.Write
+			}
+
+			client := rateLimitClient(r)
+			decision := limiter.Allow(client, class)
+			if decision.Limit > 0 {
+				w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+				w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+				w.Header().Set("RateLimit-Reset", ceilSeconds(decision.Reset))
+				w.Header().Set("RateLimit-Policy", decision.Policy)
+			}
+
+			if !decision.Allowed {
+				log.WithContext(r.Context()).Warn("Превышен лимит запросов",
+					"client", client, "class", class, "method", r.Method, "path", r.URL.Path)
+				tooManyRequests(w, decision)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitClient возвращает идентификатор клиента для учета лимитов.
+func rateLimitClient(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.ClientID()
+	}
+	return "ip:" + ClientIP(r)
+}
+
+func tooManyRequests(w http.ResponseWriter, decision ratelimit.Decision) {
+	w.Header().Set("Retry-After", ceilSeconds(decision.RetryAfter))
+	http.Error(w, "Слишком много запросов", http.StatusTooManyRequests)
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
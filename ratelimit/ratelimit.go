@@ -0,0 +1,244 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Class группа маршрутов с общими ограничениями.
+type Class string
+
+const (
+	Read  Class = "read"
+	Write Class = "write"
+)
+
+// Limit ограничения одной группы маршрутов для одного клиента.
+type Limit struct {
+	// Rate пополнение корзины токенов в секунду. 0 - без ограничения частоты.
+	Rate float64
+	// Burst емкость корзины: сколько запросов подряд можно сделать без пауз.
+	Burst int
+	// Daily сколько запросов можно сделать за сутки (UTC). 0 - без квоты.
+	Daily int
+}
+
+type Limits struct {
+	Read  Limit
+	Write Limit
+}
+
+func (l Limits) get(class Class) Limit {
+	if class == Write {
+		return l.Write
+	}
+	return l.Read
+}
+
+// Decision результат проверки запроса и данные для заголовков RateLimit-*.
+type Decision struct {
+	Allowed bool
+	// Limit, Remaining и Reset относятся к ограничению, которое ближе всего к
+	// исчерпанию: корзине токенов или суточной квоте. Limit равен 0, если
+	// ограничений нет.
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+	// RetryAfter через сколько повторить отклоненный запрос.
+	RetryAfter time.Duration
+	// Policy описание ограничений для заголовка RateLimit-Policy.
+	Policy string
+}
+
+type bucket struct {
+	class  Class
+	tokens float64
+	last   time.Time
+}
+
+type quota struct {
+	day  string
+	used int
+}
+
+// Limiter ограничивает частоту запросов корзиной токенов и суточной квотой
+// отдельно для каждого клиента и группы маршрутов.
+type Limiter struct {
+	mu      sync.Mutex
+	limits  Limits
+	buckets map[string]*bucket
+	quotas  map[string]*quota
+	pruned  time.Time
+	now     func() time.Time
+}
+
+func New(limits Limits) *Limiter {
+	return &Limiter{
+		limits:  limits,
+		buckets: make(map[string]*bucket),
+		quotas:  make(map[string]*quota),
+		now:     time.Now,
+	}
+}
+
+// SetLimits меняет ограничения на лету, накопленные корзины и квоты
+// сохраняются.
+func (l *Limiter) SetLimits(limits Limits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limits = limits
+}
+
+// SetClock подменяет источник времени, используется в тестах.
+func (l *Limiter) SetClock(now func() time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.now = now
+}
+
+// Allow учитывает запрос клиента client к группе class.
+func (l *Limiter) Allow(client string, class Class) Decision {
+	return l.decide(client, class, true)
+}
+
+// Check проверяет, остались ли у клиента client запросы к группе class, не
+// учитывая запрос. Так запрос можно отклонить до дорогой проверки, а учесть
+// только неудачный.
+func (l *Limiter) Check(client string, class Class) Decision {
+	return l.decide(client, class, false)
+}
+
+// decide проверяет запрос и, если consume, учитывает разрешенный.
+func (l *Limiter) decide(client string, class Class, consume bool) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	limit := l.limits.get(class)
+	key := string(class) + "|" + client
+
+	l.prune(now)
+
+	decision := Decision{Allowed: true, Policy: policy(limit)}
+
+	var b *bucket
+	if limit.Rate > 0 && limit.Burst > 0 {
+		b = l.refill(key, class, limit, now)
+		if b.tokens < 1 {
+			decision.Allowed = false
+			decision.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+		}
+	}
+
+	var q *quota
+	if limit.Daily > 0 {
+		q = l.quota(key, now)
+		if q.used >= limit.Daily {
+			decision.Allowed = false
+			decision.RetryAfter = max(decision.RetryAfter, untilMidnight(now))
+		}
+	}
+
+	if decision.Allowed && consume {
+		if b != nil {
+			b.tokens--
+		}
+		if q != nil {
+			q.used++
+		}
+	}
+
+	decision.Limit, decision.Remaining, decision.Reset = -1, math.MaxInt, 0
+	if b != nil {
+		decision.Limit = limit.Burst
+		decision.Remaining = int(b.tokens)
+		decision.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	}
+	if q != nil && limit.Daily-q.used < decision.Remaining {
+		decision.Limit = limit.Daily
+		decision.Remaining = limit.Daily - q.used
+		decision.Reset = untilMidnight(now)
+	}
+	if decision.Limit < 0 {
+		decision.Limit, decision.Remaining = 0, 0
+	}
+
+	return decision
+}
+
+// refill пополняет корзину клиента по прошедшему времени. Вызывается под l.mu.
+func (l *Limiter) refill(key string, class Class, limit Limit, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{class: class, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	return b
+}
+
+// quota возвращает суточный счетчик клиента, обнуляя его в начале новых
+// суток. Вызывается под l.mu.
+func (l *Limiter) quota(key string, now time.Time) *quota {
+	day := now.UTC().Format(time.DateOnly)
+
+	q, ok := l.quotas[key]
+	if !ok || q.day != day {
+		q = &quota{day: day}
+		l.quotas[key] = q
+	}
+	return q
+}
+
+// prune раз в минуту удаляет полные корзины и квоты прошлых суток, чтобы
+// не хранить всех клиентов, когда-либо обращавшихся к сервису.
+// Вызывается под l.mu.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+	l.pruned = now
+
+	for key, b := range l.buckets {
+		limit := l.limits.get(b.class)
+		if limit.Rate <= 0 || b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+
+	day := now.UTC().Format(time.DateOnly)
+	for key, q := range l.quotas {
+		if q.day != day {
+			delete(l.quotas, key)
+		}
+	}
+}
+
+func policy(limit Limit) string {
+	var parts []string
+	if limit.Rate > 0 && limit.Burst > 0 {
+		parts = append(parts, fmt.Sprintf("%d;w=%d", limit.Burst, int(math.Ceil(float64(limit.Burst)/limit.Rate))))
+	}
+	if limit.Daily > 0 {
+		parts = append(parts, fmt.Sprintf("%d;w=86400", limit.Daily))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func untilMidnight(now time.Time) time.Duration {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(now)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,80 @@
+package ratelimit_test
+
+import (
+	"quotes/ratelimit"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	limiter := ratelimit.New(ratelimit.Limits{
+		Read:  ratelimit.Limit{Rate: 1, Burst: 2},
+		Write: ratelimit.Limit{Rate: 10, Burst: 10, Daily: 3},
+	})
+	limiter.SetClock(func() time.Time { return now })
+
+	// Тест 1: Корзина позволяет Burst запросов подряд
+	for i := 0; i < 2; i++ {
+		if d := limiter.Allow("ip:1", ratelimit.Read); !d.Allowed || d.Limit != 2 || d.Remaining != 1-i {
+			t.Fatalf("Запрос %d: ожидалось разрешение, получено: %+v", i+1, d)
+		}
+	}
+
+	// Тест 2: Следующий запрос отклоняется до пополнения корзины
+	d := limiter.Allow("ip:1", ratelimit.Read)
+	if d.Allowed || d.RetryAfter != time.Second {
+		t.Errorf("Ожидался отказ с RetryAfter 1s, получено: %+v", d)
+	}
+
+	// Тест 3: Корзины клиентов независимы
+	if d := limiter.Allow("ip:2", ratelimit.Read); !d.Allowed {
+		t.Errorf("Запрос другого клиента отклонен: %+v", d)
+	}
+
+	// Тест 4: Через секунду появляется один токен
+	now = now.Add(time.Second)
+	if d := limiter.Allow("ip:1", ratelimit.Read); !d.Allowed {
+		t.Errorf("Ожидалось разрешение после пополнения: %+v", d)
+	}
+
+	// Тест 5: Суточная квота ограничивает запросы на изменение
+	for i := 0; i < 3; i++ {
+		if d := limiter.Allow("ip:1", ratelimit.Write); !d.Allowed {
+			t.Fatalf("Запрос %d на изменение отклонен: %+v", i+1, d)
+		}
+	}
+	d = limiter.Allow("ip:1", ratelimit.Write)
+	if d.Allowed || d.Limit != 3 || d.Remaining != 0 || d.RetryAfter != 59*time.Second {
+		t.Errorf("Ожидался отказ по квоте до полуночи, получено: %+v", d)
+	}
+	if d.Policy != "10;w=1, 3;w=86400" {
+		t.Errorf("Неожиданная политика: %q", d.Policy)
+	}
+
+	// Тест 6: Квота обнуляется с началом новых суток
+	now = now.Add(time.Minute)
+	if d := limiter.Allow("ip:1", ratelimit.Write); !d.Allowed {
+		t.Errorf("Ожидалось разрешение в новых сутках: %+v", d)
+	}
+
+	// Тест 7: Check не расходует токены
+	for i := 0; i < 3; i++ {
+		if d := limiter.Check("ip:3", ratelimit.Read); !d.Allowed || d.Remaining != 2 {
+			t.Fatalf("Check %d: ожидалось разрешение без учета, получено: %+v", i+1, d)
+		}
+	}
+	limiter.Allow("ip:3", ratelimit.Read)
+	limiter.Allow("ip:3", ratelimit.Read)
+	if d := limiter.Check("ip:3", ratelimit.Read); d.Allowed {
+		t.Errorf("Check должна сообщать об исчерпанном лимите: %+v", d)
+	}
+
+	// Тест 8: Новые ограничения применяются без сброса состояния
+	limiter.SetLimits(ratelimit.Limits{})
+	for i := 0; i < 5; i++ {
+		if d := limiter.Allow("ip:1", ratelimit.Write); !d.Allowed || d.Limit != 0 {
+			t.Fatalf("Без ограничений запрос отклонен: %+v", d)
+		}
+	}
+}
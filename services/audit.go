@@ -0,0 +1,86 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"quotes/audit"
+	"quotes/logger"
+	"quotes/storage"
+	"strconv"
+	"time"
+)
+
+// AuditChanges записывает каждое изменение цитат в журнал аудита. Хук
+// вызывается под блокировкой хранилища, поэтому запись только ставится в
+// очередь, а на диск попадает в фоне в том же порядке.
+func AuditChanges(s *storage.JSONStorage, a *audit.Log, log *logger.Logger) {
+	a.OnError(func(record audit.Record, err error) {
+		log.Error("Не удалось записать изменение в журнал аудита",
+			"quote_id", record.QuoteID, "action", record.Action, "error", err)
+	})
+
+	s.OnChange(func(revision storage.Revision, before, after storage.QuoteStore) {
+		a.Enqueue(audit.Record{
+			Time:    revision.Time.UTC(),
+			Actor:   revision.Actor,
+			Action:  revision.Action,
+			QuoteID: revision.QuoteID,
+			Before:  auditSnapshot(before),
+			After:   auditSnapshot(after),
+		})
+	})
+}
+
+func auditSnapshot(quote storage.QuoteStore) json.RawMessage {
+	if quote.ID == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(quote)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func QueryAudit(a *audit.Log, log *logger.Logger, r *http.Request) ([]audit.Record, error) {
+	params := r.URL.Query()
+
+	filter := audit.Filter{
+		Actor:  params.Get("actor"),
+		Action: params.Get("action"),
+		Limit:  100,
+	}
+
+	var err error
+	if value := params.Get("quote_id"); value != "" {
+		if filter.QuoteID, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("Неверный формат quote_id: %v", err)
+		}
+	}
+	if value := params.Get("since"); value != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, fmt.Errorf("Неверный формат since, ожидается RFC3339: %v", err)
+		}
+	}
+	if value := params.Get("until"); value != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, fmt.Errorf("Неверный формат until, ожидается RFC3339: %v", err)
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit <= 0 {
+			return nil, fmt.Errorf("Неверное значение limit: %s", value)
+		}
+	}
+
+	records, err := a.Query(filter)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка чтения журнала аудита: %w", err)
+	}
+
+	log.Info("Получение журнала аудита прошло успешно", "found", len(records))
+
+	return records, nil
+}
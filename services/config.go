@@ -0,0 +1,28 @@
+package services
+
+import (
+	"fmt"
+	"quotes/config"
+	"quotes/logger"
+)
+
+// ReloadConfig перечитывает конфигурацию и записывает в лог каждое изменение.
+func ReloadConfig(reloader *config.Reloader, log *logger.Logger) ([]config.Change, error) {
+	changes, err := reloader.Reload()
+	if err != nil {
+		return nil, fmt.Errorf("Новая конфигурация отклонена: %w", err)
+	}
+
+	if len(changes) == 0 {
+		log.Info("Конфигурация перечитана, изменений нет")
+	}
+	for _, change := range changes {
+		if change.Applied {
+			log.Info("Параметр конфигурации изменен", "key", change.Key, "old", change.Old, "new", change.New)
+		} else {
+			log.Warn("Параметр конфигурации вступит в силу после перезапуска", "key", change.Key, "old", change.Old, "new", change.New)
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"quotes/storage"
+	"strconv"
+	"strings"
+)
+
+// ETag возвращает ETag цитаты вида "версия-лайки-оценки-сумма оценок", а для
+// показанного перевода с суффиксом языка: "3-0-0-0-en". Лайки, оценки и
+// выбранный по Accept-Language перевод не меняют версию, но меняют ответ,
+// поэтому тоже входят в ETag. If-Match сверяет только версию.
+func ETag(quote storage.QuoteStore) string {
+	sum := math.Round(quote.RatingAverage * float64(quote.RatingCount))
+	if quote.Translated != "" {
+		return fmt.Sprintf(`"%d-%d-%d-%.0f-%s"`, quote.Version, quote.Likes, quote.RatingCount, sum, quote.Translated)
+	}
+	return fmt.Sprintf(`"%d-%d-%d-%.0f"`, quote.Version, quote.Likes, quote.RatingCount, sum)
+}
+
+// ListETag возвращает ETag списка цитат, построенный по их ID, версиям,
+// голосам и языкам показанных переводов, чтобы ответы на разные
+// Accept-Language не считались одним представлением.
+func ListETag(quotes []storage.QuoteStore) string {
+	hash := sha1.New()
+	for _, quote := range quotes {
+		fmt.Fprintf(hash, "%d:%d:%d:%g:%s;", quote.ID, quote.Version, quote.Likes, quote.RatingAverage, quote.Translated)
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+}
+
+// MatchesETag сообщает, совпадает ли etag с одним из значений заголовка
+// If-None-Match. Сравнение слабое: префикс W/ не учитывается.
+func MatchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion переводит заголовок If-Match в версию для проверки
+// хранилищем. Ноль означает, что проверка не нужна.
+func ifMatchVersion(s *storage.JSONStorage, r *http.Request, id int) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	var versions []int
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		value, _, _ := strings.Cut(strings.Trim(candidate, `"`), "-")
+		version, err := strconv.Atoi(value)
+		if err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+
+	if len(versions) == 0 {
+		return 0, fmt.Errorf("%w: некорректный If-Match %s", storage.ErrVersionMismatch, header)
+	}
+	if len(versions) == 1 {
+		return versions[0], nil
+	}
+
+	current, err := s.GetQuote(r.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+	for _, version := range versions {
+		if version == current.Version {
+			return version, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: текущая %d", storage.ErrVersionMismatch, current.Version)
+}
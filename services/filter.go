@@ -0,0 +1,69 @@
+package services
+
+import (
+	"net/http"
+	"quotes/auth"
+	"quotes/filter"
+	"quotes/logger"
+	"quotes/storage"
+	"slices"
+	"strings"
+)
+
+// filterQuote проверяет текст, автора и теги цитаты фильтром содержимого и
+// маскирует найденное на месте. Возвращает правила, потребовавшие
+// модерации, или *filter.RejectedError, если цитата отклонена.
+func filterQuote(filters *filter.Chain, quote *storage.Quote, log *logger.Logger) ([]string, error) {
+	if filters.Len() == 0 {
+		return nil, nil
+	}
+
+	var flags []string
+	check := func(field string, text *string) error {
+		result := filters.Check(*text)
+		if len(result.Violations) == 0 {
+			return nil
+		}
+		log.Warn("Сработал фильтр содержимого", "field", field, "action", result.Action, "violations", result.Violations)
+
+		if err := result.Rejection(); err != nil {
+			return err
+		}
+		*text = result.Text
+		for _, flag := range result.Flags() {
+			if !slices.Contains(flags, flag) {
+				flags = append(flags, flag)
+			}
+		}
+		return nil
+	}
+
+	if err := check("quote", &quote.Quote); err != nil {
+		return nil, err
+	}
+	if err := check("author", &quote.Author); err != nil {
+		return nil, err
+	}
+	for i := range quote.Tags {
+		if err := check("tags", &quote.Tags[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return flags, nil
+}
+
+// filterUpdate проверяет измененную цитату и возвращает сработавшие правила.
+// Если moderated, изменение уходит на модерацию вместе с ними; иначе
+// помеченное фильтром изменение клиента без права на модерацию отклоняется,
+// потому что проверить его некому.
+func filterUpdate(filters *filter.Chain, r *http.Request, quote *storage.Quote, moderated bool, log *logger.Logger) ([]string, error) {
+	flags, err := filterQuote(filters, quote, log)
+	if err != nil {
+		return nil, err
+	}
+	if len(flags) > 0 && !moderated && !auth.RoleOf(r.Context()).Can(auth.PermModerate) {
+		return nil, &filter.RejectedError{Reason: "изменение требует проверки модератором, правила " + strings.Join(flags, ", ")}
+	}
+	return flags, nil
+}
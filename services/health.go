@@ -0,0 +1,130 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"quotes/logger"
+	"quotes/storage"
+	"time"
+)
+
+// Health сведения о запущенном процессе для /readyz и /status.
+type Health struct {
+	Version   string
+	StartedAt time.Time
+	JSONPath  string
+}
+
+type Check struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type Readiness struct {
+	Ready  bool    `json:"ready"`
+	Checks []Check `json:"checks"`
+}
+
+type StorageStatus struct {
+	Backend       string     `json:"backend"`
+	Path          string     `json:"path"`
+	Quotes        int        `json:"quotes"`
+	Authors       int        `json:"authors"`
+	LastSavedAt   *time.Time `json:"last_saved_at,omitempty"`
+	LastSaveError string     `json:"last_save_error,omitempty"`
+}
+
+type Status struct {
+	Version   string        `json:"version"`
+	StartedAt time.Time     `json:"started_at"`
+	Uptime    string        `json:"uptime"`
+	Ready     bool          `json:"ready"`
+	Storage   StorageStatus `json:"storage"`
+}
+
+// CheckReadiness проверяет, что хранилище загружено и его файл доступен для
+// чтения, последнее сохранение прошло успешно и в каталог хранилища можно
+// писать.
+func CheckReadiness(s *storage.JSONStorage, h Health, log *logger.Logger) Readiness {
+	checks := []Check{
+		newCheck("storage", storageLoaded(s, h.JSONPath)),
+		newCheck("last_save", lastSaveSucceeded(s)),
+		newCheck("disk", diskWritable(h.JSONPath)),
+	}
+
+	readiness := Readiness{Ready: true, Checks: checks}
+	for _, check := range checks {
+		if !check.OK {
+			readiness.Ready = false
+			log.Warn("Проверка готовности не пройдена", "check", check.Name, "error", check.Error)
+		}
+	}
+
+	return readiness
+}
+
+func newCheck(name string, err error) Check {
+	if err != nil {
+		return Check{Name: name, Error: err.Error()}
+	}
+	return Check{Name: name, OK: true}
+}
+
+func storageLoaded(s *storage.JSONStorage, path string) error {
+	if s == nil {
+		return fmt.Errorf("Хранилище не инициализировано")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Файл хранилища недоступен для чтения: %w", err)
+	}
+	return file.Close()
+}
+
+func lastSaveSucceeded(s *storage.JSONStorage) error {
+	if s == nil {
+		return nil
+	}
+	if _, err := s.LastSave(); err != nil {
+		return fmt.Errorf("Последнее сохранение завершилось ошибкой: %w", err)
+	}
+	return nil
+}
+
+func diskWritable(path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), ".readyz-*")
+	if err != nil {
+		return fmt.Errorf("Каталог хранилища недоступен для записи: %w", err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+func GetStatus(s *storage.JSONStorage, h Health, log *logger.Logger) Status {
+	status := Status{
+		Version:   h.Version,
+		StartedAt: h.StartedAt,
+		Uptime:    time.Since(h.StartedAt).Round(time.Second).String(),
+		Ready:     CheckReadiness(s, h, log).Ready,
+		Storage: StorageStatus{
+			Backend: "json",
+			Path:    h.JSONPath,
+		},
+	}
+
+	if s != nil {
+		status.Storage.Quotes, status.Storage.Authors = s.Counts()
+
+		savedAt, err := s.LastSave()
+		if !savedAt.IsZero() {
+			status.Storage.LastSavedAt = &savedAt
+		}
+		if err != nil {
+			status.Storage.LastSaveError = err.Error()
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"quotes/auth"
+	"quotes/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// CreatedKey ответ на создание ключа. Token показывается только один раз.
+type CreatedKey struct {
+	Key   auth.Key `json:"key"`
+	Token string   `json:"token"`
+}
+
+func ListKeys(k *auth.KeyStore, log *logger.Logger) []auth.Key {
+	keys := k.List()
+
+	log.Info("Получение списка API-ключей прошло успешно", "found", len(keys))
+
+	return keys
+}
+
+func CreateKey(k *auth.KeyStore, log *logger.Logger, r *http.Request) (CreatedKey, error) {
+	defer r.Body.Close()
+
+	var request struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	if err := decodeJSON(r, &request); err != nil {
+		return CreatedKey{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+	if request.Role == "" {
+		request.Role = string(auth.RoleContributor)
+	}
+
+	role, err := auth.ParseRole(request.Role)
+	if err != nil {
+		return CreatedKey{}, err
+	}
+
+	key, token, err := k.Create(request.Name, role)
+	if err != nil {
+		return CreatedKey{}, fmt.Errorf("Ошибка при создании API-ключа: %w", err)
+	}
+
+	log.Info("Создан API-ключ", "id", key.ID, "name", key.Name, "role", key.Role, "actor", Actor(r))
+
+	return CreatedKey{Key: key, Token: token}, nil
+}
+
+func RevokeKey(k *auth.KeyStore, log *logger.Logger, r *http.Request) (auth.Key, error) {
+	key, err := k.Revoke(mux.Vars(r)["id"])
+	if err != nil {
+		return auth.Key{}, fmt.Errorf("Ошибка при отзыве API-ключа: %w", err)
+	}
+
+	log.Info("Отозван API-ключ", "id", key.ID, "name", key.Name, "actor", Actor(r))
+
+	return key, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"quotes/auth"
+	"quotes/logger"
+	"quotes/storage"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Moderated сообщает, должна ли цитата из запроса пройти модерацию:
+// модерация включена, а у клиента нет права на модерацию.
+func Moderated(r *http.Request, enabled bool) bool {
+	return enabled && !auth.RoleOf(r.Context()).Can(auth.PermModerate)
+}
+
+func GetModerationQueue(s *storage.JSONStorage, log *logger.Logger, r *http.Request) []storage.QuoteStore {
+	queue := s.ModerationQueue(r.Context())
+
+	log.Info("Получение очереди модерации прошло успешно", "found", len(queue))
+
+	return queue
+}
+
+func Approve(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	quote, err := s.Approve(r.Context(), id, Actor(r))
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при одобрении цитаты: %w", err)
+	}
+
+	log.Info("Цитата одобрена", "id", id, "actor", quote.Moderation.Actor)
+
+	return quote, nil
+}
+
+// Reject отклоняет цитату. Причина обязательна и сохраняется в цитате.
+func Reject(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err = decodeJSON(r, &request); err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return storage.QuoteStore{}, errors.New("Причина отклонения обязательна")
+	}
+
+	quote, err := s.Reject(r.Context(), id, Actor(r), reason)
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при отклонении цитаты: %w", err)
+	}
+
+	log.Info("Цитата отклонена", "id", id, "actor", quote.Moderation.Actor, "reason", reason)
+
+	return quote, nil
+}
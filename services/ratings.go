@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"quotes/logger"
+	"quotes/storage"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func Like(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	quote, err := s.Like(r.Context(), id, clientID(r))
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при добавлении лайка: %w", err)
+	}
+
+	log.Info("Лайк цитаты прошел успешно", "id", id, "likes", quote.Likes)
+
+	return quote, nil
+}
+
+func Unlike(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	quote, err := s.Unlike(r.Context(), id, clientID(r))
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при отмене лайка: %w", err)
+	}
+
+	log.Info("Отмена лайка цитаты прошла успешно", "id", id, "likes", quote.Likes)
+
+	return quote, nil
+}
+
+func Rate(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	var request struct {
+		Stars int `json:"stars"`
+	}
+	if err = decodeJSON(r, &request); err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+
+	quote, err := s.Rate(r.Context(), id, clientID(r), request.Stars)
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при оценке цитаты: %w", err)
+	}
+
+	log.Info("Оценка цитаты прошла успешно", "id", id, "stars", request.Stars, "average", quote.RatingAverage)
+
+	return quote, nil
+}
+
+func GetTopRated(s *storage.JSONStorage, log *logger.Logger, r *http.Request) ([]storage.RatedQuote, error) {
+	limit := 10
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("Неверное значение limit: %s", value)
+		}
+		if limit > 50 {
+			limit = 50
+		}
+	}
+
+	quotes := s.TopRated(r.Context(), limit)
+
+	log.Info("Получение лучших цитат прошло успешно", "found", len(quotes))
+
+	return quotes, nil
+}
+
+// sortQuotes упорядочивает цитаты по параметру sort: popular - по числу
+// лайков, затем по средней оценке. Без параметра порядок не меняется.
+func sortQuotes(quotes []storage.QuoteStore, by string) error {
+	switch by {
+	case "":
+	case "popular":
+		sort.SliceStable(quotes, func(i, j int) bool {
+			if quotes[i].Likes != quotes[j].Likes {
+				return quotes[i].Likes > quotes[j].Likes
+			}
+			return quotes[i].RatingAverage > quotes[j].RatingAverage
+		})
+	default:
+		return fmt.Errorf("Неверное значение sort: %s", by)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"quotes/auth"
+	"quotes/logger"
+	"quotes/storage"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+const maxReportComment = 1000
+
+// ReportResult ответ на жалобу. Другие жалобы и их авторы клиенту не
+// показываются.
+type ReportResult struct {
+	QuoteID  int    `json:"quote_id"`
+	Category string `json:"category"`
+	Hidden   bool   `json:"hidden"`
+}
+
+// Report сохраняет жалобу клиента на цитату. threshold - число открытых
+// жалоб, после которого цитата скрывается, см. storage.JSONStorage.Report.
+func Report(s *storage.JSONStorage, threshold int, log *logger.Logger, r *http.Request) (ReportResult, error) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return ReportResult{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	var request struct {
+		Category string `json:"category"`
+		Comment  string `json:"comment"`
+	}
+	if err = decodeJSON(r, &request); err != nil {
+		return ReportResult{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+	comment := strings.TrimSpace(request.Comment)
+	if utf8.RuneCountInString(comment) > maxReportComment {
+		return ReportResult{}, fmt.Errorf("Комментарий к жалобе не должен быть длиннее %d символов", maxReportComment)
+	}
+
+	_, authenticated := auth.FromContext(r.Context())
+	summary, err := s.Report(r.Context(), id, clientID(r), !authenticated, request.Category, comment, threshold)
+	if err != nil {
+		return ReportResult{}, fmt.Errorf("Ошибка при отправке жалобы: %w", err)
+	}
+
+	log.Info("Жалоба на цитату принята", "id", id, "category", request.Category, "open", summary.Open)
+	if summary.Quote.Hidden {
+		log.Warn("Цитата скрыта из-за жалоб", "id", id, "open", summary.Open, "threshold", threshold)
+	}
+
+	return ReportResult{QuoteID: id, Category: request.Category, Hidden: summary.Quote.Hidden}, nil
+}
+
+// GetReports возвращает жалобы по цитатам. Параметр status: open (по
+// умолчанию), resolved или all.
+func GetReports(s *storage.JSONStorage, log *logger.Logger, r *http.Request) ([]storage.ReportSummary, error) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = "open"
+	case "open", "resolved", "all":
+	default:
+		return nil, fmt.Errorf("Неверное значение status: %s", status)
+	}
+
+	reports := s.Reports(r.Context(), status)
+
+	log.Info("Получение жалоб прошло успешно", "status", status, "found", len(reports))
+
+	return reports, nil
+}
+
+// ResolveReports закрывает открытые жалобы на цитату решением из тела
+// запроса: dismiss или remove.
+func ResolveReports(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.ReportSummary, error) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.ReportSummary{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	var request struct {
+		Resolution string `json:"resolution"`
+		Note       string `json:"note"`
+	}
+	if err = decodeJSON(r, &request); err != nil {
+		return storage.ReportSummary{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+
+	summary, err := s.ResolveReports(r.Context(), id, request.Resolution, Actor(r), strings.TrimSpace(request.Note))
+	if err != nil {
+		return storage.ReportSummary{}, fmt.Errorf("Ошибка при рассмотрении жалоб: %w", err)
+	}
+
+	log.Info("Жалобы на цитату рассмотрены", "id", id, "resolution", request.Resolution, "actor", Actor(r))
+
+	return summary, nil
+}
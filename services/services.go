@@ -1,35 +1,64 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
+	"net"
 	"net/http"
+	"net/netip"
+	"quotes/auth"
+	"quotes/filter"
 	"quotes/logger"
 	"quotes/storage"
+	"quotes/tracing"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
-func Add(s *storage.JSONStorage, r *http.Request, log *logger.Logger) error {
+// Add добавляет цитату из запроса после проверки фильтром содержимого.
+// Цитата отправляется в очередь модерации, если moderated или если ее пометил
+// фильтр, а у клиента нет права на модерацию.
+func Add(s *storage.JSONStorage, filters *filter.Chain, moderated bool, r *http.Request, log *logger.Logger) (storage.QuoteStore, error) {
 	defer r.Body.Close()
 
 	var quote storage.Quote
-	err := json.NewDecoder(r.Body).Decode(&quote)
+	err := decodeJSON(r, &quote)
 	if err != nil {
-		return fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+		return storage.QuoteStore{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+	if quote.Language, err = normalizeLanguage(quote.Language); err != nil {
+		return storage.QuoteStore{}, err
+	}
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		quote.Owner = principal.ClientID()
+	}
+
+	flags, err := filterQuote(filters, &quote, log)
+	if err != nil {
+		return storage.QuoteStore{}, err
+	}
+	if len(flags) > 0 && !auth.RoleOf(r.Context()).Can(auth.PermModerate) {
+		moderated = true
 	}
 
-	s.Add(quote)
+	if moderated {
+		submitted := s.Submit(r.Context(), quote, Actor(r), flags...)
+		log.Info("Цитата отправлена на модерацию", "id", submitted.ID, "author", quote.Author, "actor", submitted.SubmittedBy, "flags", flags)
+		return submitted, nil
+	}
 
-	log.Info("Добавление новой цитаты прошло успешно (Author: " + quote.Author + "; Text: " + quote.Quote + ")")
+	added := s.Add(r.Context(), quote, Actor(r))
 
-	return nil
+	log.Info("Добавление новой цитаты прошло успешно", "author", quote.Author, "quote", quote.Quote)
+
+	return added, nil
 }
 
-func GetQuotes(s *storage.JSONStorage, log *logger.Logger, r *http.Request) ([]storage.Quote, error) {
-	quotes, err := s.GetQuotes()
+func GetQuotes(s *storage.JSONStorage, log *logger.Logger, r *http.Request) ([]storage.QuoteStore, error) {
+	quotes, err := s.GetQuotes(r.Context())
 	if err != nil {
 		return quotes, err
 	}
@@ -39,31 +68,181 @@ func GetQuotes(s *storage.JSONStorage, log *logger.Logger, r *http.Request) ([]s
 	params := r.URL.Query()
 	author := params.Get("author")
 
-	var response []storage.Quote
+	var response []storage.QuoteStore
 
 	if author == "" {
-		return quotes, nil
+		response = quotes
 	} else {
 		for _, quote := range quotes {
 			if author == quote.Author {
 				response = append(response, quote)
 			}
 		}
-		return response, nil
 	}
+
+	if err = sortQuotes(response, params.Get("sort")); err != nil {
+		return nil, err
+	}
+
+	preferred := acceptLanguages(r)
+	for i := range response {
+		response[i] = localize(response[i], preferred)
+	}
+	return response, nil
 }
 
-func GetRandom(s *storage.JSONStorage, log *logger.Logger) (storage.Quote, error) {
-	quotes, err := s.GetQuotes()
+func GetQuote(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	quote, err := s.GetQuote(r.Context(), id)
 	if err != nil {
-		return storage.Quote{}, err
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при получении цитаты: %w", err)
 	}
 
-	randomQuote := quotes[rand.Intn(len(quotes))]
+	log.Info("Получение цитаты прошло успешно", "id", id)
+
+	return quote, nil
+}
+
+func GetRandom(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	randomQuote, err := s.GetRandom(r.Context())
+	if err != nil {
+		return storage.QuoteStore{}, err
+	}
 
 	log.Info("Получение случайной цитаты прошло успешно")
 
-	return randomQuote, nil
+	return localize(randomQuote, acceptLanguages(r)), nil
+}
+
+// Update заменяет цитату. Если moderated, измененная цитата возвращается в
+// очередь модерации и не видна до одобрения, см. Moderated.
+func Update(s *storage.JSONStorage, filters *filter.Chain, moderated bool, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	var quote storage.Quote
+	if err = decodeJSON(r, &quote); err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+	if quote.Quote == "" || quote.Author == "" {
+		return storage.QuoteStore{}, fmt.Errorf("Текст цитаты и автор обязательны")
+	}
+	if quote.Language, err = normalizeLanguage(quote.Language); err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	if err = authorizeUpdate(s, r, id); err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	version, err := ifMatchVersion(s, r, id)
+	if err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	flags, err := filterUpdate(filters, r, &quote, moderated, log)
+	if err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	updated, err := saveUpdate(s, r, id, quote, version, moderated, flags)
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при изменении цитаты: %w", err)
+	}
+
+	log.Info("Изменение цитаты прошло успешно", "id", id, "version", updated.Version, "status", updated.Status)
+
+	return updated, nil
+}
+
+type quotePatch struct {
+	Quote    *string   `json:"quote"`
+	Author   *string   `json:"author"`
+	Tags     *[]string `json:"tags"`
+	Language *string   `json:"language"`
+}
+
+// Patch изменяет только переданные поля цитаты. Без If-Match изменение
+// применяется к версии, прочитанной перед ним, чтобы не затереть
+// параллельную правку. moderated - как в Update.
+func Patch(s *storage.JSONStorage, filters *filter.Chain, moderated bool, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	var patch quotePatch
+	if err = decodeJSON(r, &patch); err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+
+	if err = authorizeUpdate(s, r, id); err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	version, err := ifMatchVersion(s, r, id)
+	if err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	current, err := s.GetQuote(r.Context(), id)
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при изменении цитаты: %w", err)
+	}
+	if version == 0 {
+		version = current.Version
+	}
+
+	quote := storage.Quote{Quote: current.Quote, Author: current.Author, Tags: current.Tags, Language: current.Language}
+	if patch.Quote != nil {
+		quote.Quote = *patch.Quote
+	}
+	if patch.Author != nil {
+		quote.Author = *patch.Author
+	}
+	if patch.Tags != nil {
+		quote.Tags = *patch.Tags
+	}
+	if patch.Language != nil {
+		if quote.Language, err = normalizeLanguage(*patch.Language); err != nil {
+			return storage.QuoteStore{}, err
+		}
+	}
+	if quote.Quote == "" || quote.Author == "" {
+		return storage.QuoteStore{}, fmt.Errorf("Текст цитаты и автор обязательны")
+	}
+	flags, err := filterUpdate(filters, r, &quote, moderated, log)
+	if err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	updated, err := saveUpdate(s, r, id, quote, version, moderated, flags)
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при изменении цитаты: %w", err)
+	}
+
+	log.Info("Частичное изменение цитаты прошло успешно", "id", id, "version", updated.Version, "status", updated.Status)
+
+	return updated, nil
+}
+
+// saveUpdate сохраняет изменение цитаты или, если moderated, отправляет
+// измененную цитату на модерацию.
+func saveUpdate(s *storage.JSONStorage, r *http.Request, id int, quote storage.Quote, version int, moderated bool, flags []string) (storage.QuoteStore, error) {
+	if moderated {
+		return s.SubmitUpdate(r.Context(), id, quote, version, Actor(r), flags...)
+	}
+	return s.UpdateQuoteID(r.Context(), id, quote, version, Actor(r))
 }
 
 func Delete(s *storage.JSONStorage, log *logger.Logger, r *http.Request) error {
@@ -72,9 +251,251 @@ func Delete(s *storage.JSONStorage, log *logger.Logger, r *http.Request) error {
 		return fmt.Errorf("Неверный формат ID: %v", err)
 	}
 
-	if err = s.DeleteQuoteID(id); err != nil {
-		return fmt.Errorf("Ошибка при удалении цитаты: %v", err)
+	version, err := ifMatchVersion(s, r, id)
+	if err != nil {
+		return err
+	}
+
+	if err = s.DeleteQuoteID(r.Context(), id, version, Actor(r)); err != nil {
+		return fmt.Errorf("Ошибка при удалении цитаты: %w", err)
 	}
 
 	return nil
 }
+
+func GetHistory(s *storage.JSONStorage, log *logger.Logger, r *http.Request) ([]storage.Revision, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	// История скрытых цитат раскрыла бы их текст, поэтому ее видят только
+	// те, кто может восстанавливать или модерировать цитаты.
+	role := auth.RoleOf(r.Context())
+	hidden := role.Can(auth.PermRestore) || role.Can(auth.PermModerate)
+
+	revisions, err := s.GetHistory(r.Context(), id, hidden)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при получении истории цитаты: %w", err)
+	}
+
+	log.Info("Получение истории цитаты прошло успешно", "id", id, "revisions", len(revisions))
+
+	return revisions, nil
+}
+
+func Revert(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	rev, err := strconv.Atoi(vars["rev"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ревизии: %v", err)
+	}
+
+	quote, err := s.Revert(r.Context(), id, rev, Actor(r))
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при откате цитаты: %w", err)
+	}
+
+	log.Info("Откат цитаты прошел успешно", "id", id, "rev", rev)
+
+	return quote, nil
+}
+
+// authorizeUpdate проверяет, что клиент может изменить цитату id: редактор -
+// любую, участник - только добавленную им самим. Доступ к маршруту в целом
+// проверяет middleware.Require, поэтому запросы без клиента в контексте
+// (внутренние вызовы) здесь не ограничиваются.
+func authorizeUpdate(s *storage.JSONStorage, r *http.Request, id int) error {
+	principal, ok := auth.FromContext(r.Context())
+	if !ok || principal.Role.Can(auth.PermUpdate) {
+		return nil
+	}
+
+	quote, err := s.GetQuote(r.Context(), id)
+	if err != nil {
+		return fmt.Errorf("Ошибка при изменении цитаты: %w", err)
+	}
+	return auth.AuthorizeOwner(r.Context(), quote.Owner)
+}
+
+// decodeJSON декодирует тело запроса в v, выделяя декодирование в отдельный span.
+func decodeJSON(r *http.Request, v any) error {
+	_, span := tracing.Start(r.Context(), "services.decode_json")
+	defer span.End()
+
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// Actor определяет, от чьего имени выполняется изменение: аутентифицированный
+// клиент или адрес анонимного клиента. Заголовки запроса не учитываются,
+// чтобы клиент не мог подставить чужое имя в историю и журнал аудита.
+func Actor(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.Subject
+	}
+	return remoteIP(r)
+}
+
+// clientID определяет, от чьего имени ставится лайк, оценка или жалоба:
+// пользователь, API-ключ, субъект JWT или адрес анонимного клиента.
+func clientID(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.ClientID()
+	}
+	return "ip:" + clientNetwork(remoteIP(r))
+}
+
+// clientNetwork возвращает адрес IPv4 без изменений, а для IPv6 его сеть
+// /64: абонент обычно получает всю сеть и может менять адреса внутри нее.
+func clientNetwork(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	if addr = addr.Unmap(); addr.Is4() {
+		return addr.String()
+	}
+	prefix, err := addr.Prefix(64)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+// remoteIP возвращает адрес клиента из соединения без порта.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func SuggestAuthors(s *storage.JSONStorage, log *logger.Logger, r *http.Request) ([]storage.AuthorSuggestion, error) {
+	params := r.URL.Query()
+
+	limit := 10
+	if value := params.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("Неверное значение limit: %s", value)
+		}
+		if limit > 50 {
+			limit = 50
+		}
+	}
+
+	suggestions := s.SuggestAuthors(r.Context(), params.Get("prefix"), limit)
+
+	log.Info("Получение подсказок по авторам прошло успешно", "prefix", params.Get("prefix"), "found", len(suggestions))
+
+	return suggestions, nil
+}
+
+// GetStats возвращает статистику хранилища; параметр top ограничивает длину
+// списков (не больше 50). Счетчики показов (most_served) хранятся только в
+// памяти и обнуляются при перезапуске сервера.
+func GetStats(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.Stats, error) {
+	limit := 10
+	if value := r.URL.Query().Get("top"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return storage.Stats{}, fmt.Errorf("Неверное значение top: %s", value)
+		}
+		if limit > 50 {
+			limit = 50
+		}
+	}
+
+	stats := s.Stats(r.Context(), limit)
+
+	log.Info("Получение статистики прошло успешно")
+
+	return stats, nil
+}
+
+func GetTrash(s *storage.JSONStorage, log *logger.Logger, r *http.Request) []storage.QuoteStore {
+	trash := s.GetTrash(r.Context())
+
+	log.Info("Получение корзины прошло успешно")
+
+	return trash
+}
+
+func Restore(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	quote, err := s.Restore(r.Context(), id, Actor(r))
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при восстановлении цитаты: %w", err)
+	}
+
+	log.Info("Восстановление цитаты из корзины прошло успешно", "id", id)
+
+	return quote, nil
+}
+
+// RunAutosave сохраняет изменения хранилища в path раз в interval(), пока не
+// будет закрыт stop, чтобы изменения не терялись при аварийном завершении, а
+// ошибки записи были видны в /readyz. Вместе с хранилищем сохраняются
+// отметки использования API-ключей keys (если они есть), так что файл ключей
+// переписывается не чаще раза в интервал. Интервал запрашивается перед каждым
+// ожиданием, нулевой интервал отключает сохранение до перезагрузки
+// конфигурации.
+func RunAutosave(s *storage.JSONStorage, keys *auth.KeyStore, path string, log *logger.Logger, interval func() time.Duration, stop <-chan struct{}) {
+	for {
+		wait := interval()
+		enabled := wait > 0
+		if !enabled {
+			wait = time.Minute
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+			if !enabled {
+				continue
+			}
+			if _, err := s.SaveChanges(context.Background(), path, log); err != nil {
+				log.Error("Не удалось сохранить данные", "path", path, "error", err)
+			}
+			if keys == nil {
+				continue
+			}
+			if err := keys.Save(); err != nil {
+				log.Error("Не удалось сохранить API-ключи", "error", err)
+			}
+		}
+	}
+}
+
+// RunTrashPurge раз в interval удаляет из корзины цитаты старше retention(),
+// пока не будет закрыт stop. Срок хранения запрашивается перед каждой
+// очисткой, чтобы учитывать перезагрузку конфигурации.
+func RunTrashPurge(s *storage.JSONStorage, log *logger.Logger, retention func() time.Duration, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if purged := s.PurgeTrash(context.Background(), retention()); purged > 0 {
+				log.Info("Очистка корзины", "purged", purged)
+			}
+		}
+	}
+}
@@ -2,25 +2,32 @@ package services_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"quotes/auth"
+	"quotes/filter"
 	"quotes/logger"
 	"quotes/services"
 	"quotes/storage"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 func TestAdd(t *testing.T) {
-	log, err := logger.NewLogger()
+	log, err := logger.New(logger.Options{Outputs: []string{filepath.Join(t.TempDir(), "log.log")}})
 	if err != nil {
 		t.Fatalf("Не удалось создать логгер: %v", err)
 	}
-	defer os.Remove("log.log")
 
 	s, err := storage.CreateJSONStorage("temp_JSON.json", log)
 	if err != nil {
@@ -41,7 +48,7 @@ func TestAdd(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
 
-	err = services.Add(s, req, log)
+	_, err = services.Add(s, nil, false, req, log)
 	if err != nil {
 		t.Fatalf("Add вернула ошибку: %v", err)
 	}
@@ -56,11 +63,10 @@ func TestAdd(t *testing.T) {
 }
 
 func TestGetQuotes(t *testing.T) {
-	log, err := logger.NewLogger()
+	log, err := logger.New(logger.Options{Outputs: []string{filepath.Join(t.TempDir(), "log.log")}})
 	if err != nil {
 		t.Fatalf("Не удалось создать логгер: %v", err)
 	}
-	defer os.Remove("log.log")
 
 	s, err := storage.CreateJSONStorage("temp_JSON.json", log)
 	if err != nil {
@@ -74,7 +80,7 @@ func TestGetQuotes(t *testing.T) {
 		{Quote: "Quote 3", Author: "Author 1"},
 	}
 	for _, quote := range quotes {
-		s.Add(quote)
+		s.Add(context.Background(), quote, "")
 	}
 
 	// Тест 1: Получение всех цитат
@@ -115,11 +121,10 @@ func TestGetQuotes(t *testing.T) {
 
 func TestGetRandom(t *testing.T) {
 	// Создаем логгер
-	log, err := logger.NewLogger()
+	log, err := logger.New(logger.Options{Outputs: []string{filepath.Join(t.TempDir(), "log.log")}})
 	if err != nil {
 		t.Fatalf("Не удалось создать логгер: %v", err)
 	}
-	defer os.Remove("log.log")
 
 	s, err := storage.CreateJSONStorage("temp_JSON.json", log)
 	if err != nil {
@@ -133,13 +138,15 @@ func TestGetRandom(t *testing.T) {
 		{Quote: "Quote 3", Author: "Author 1"},
 	}
 	for _, quote := range quotes {
-		s.Add(quote)
+		s.Add(context.Background(), quote, "")
 	}
 
 	rand.Seed(3)
 
+	req := httptest.NewRequest(http.MethodGet, "/quotes/random", nil)
+
 	// Тест 1: Получение случайной цитаты
-	randomQuote, err := services.GetRandom(s, log)
+	randomQuote, err := services.GetRandom(s, log, req)
 	if err != nil {
 		t.Fatalf("GetRandom вернула ошибку: %v", err)
 	}
@@ -162,18 +169,17 @@ func TestGetRandom(t *testing.T) {
 	}
 	defer os.Remove("empty_JSON.json")
 
-	_, err = services.GetRandom(emptyStorage, log)
+	_, err = services.GetRandom(emptyStorage, log, req)
 	if err == nil {
 		t.Error("Ожидалась ошибка при получении случайной цитаты из пустого хранилища")
 	}
 }
 
 func TestDelete(t *testing.T) {
-	log, err := logger.NewLogger()
+	log, err := logger.New(logger.Options{Outputs: []string{filepath.Join(t.TempDir(), "log.log")}})
 	if err != nil {
 		t.Fatalf("Не удалось создать логгер: %v", err)
 	}
-	defer os.Remove("log.log")
 
 	s, err := storage.CreateJSONStorage("temp_JSON.json", log)
 	if err != nil {
@@ -187,7 +193,7 @@ func TestDelete(t *testing.T) {
 		{Quote: "Quote 3", Author: "Author 1"},
 	}
 	for _, quote := range quotes {
-		s.Add(quote)
+		s.Add(context.Background(), quote, "")
 	}
 
 	// Тест 1: Удаление существующей цитаты
@@ -200,8 +206,15 @@ func TestDelete(t *testing.T) {
 		t.Fatalf("Delete вернула ошибку: %v", err)
 	}
 
-	if len(s.Quotes) != len(quotes)-1 {
-		t.Errorf("Ожидалось %d цитат, получено: %d", len(quotes)-1, len(s.Quotes))
+	remaining, err := s.GetQuotes(context.Background())
+	if err != nil {
+		t.Fatalf("GetQuotes вернула ошибку: %v", err)
+	}
+	if len(remaining) != len(quotes)-1 {
+		t.Errorf("Ожидалось %d цитат, получено: %d", len(quotes)-1, len(remaining))
+	}
+	if trash := s.GetTrash(context.Background()); len(trash) != 1 || trash[0].ID != 1 {
+		t.Errorf("Ожидалась цитата с ID 1 в корзине, получено: %+v", trash)
 	}
 
 	// Тест 2: Удаление несуществующей цитаты
@@ -223,4 +236,742 @@ func TestDelete(t *testing.T) {
 	if err == nil {
 		t.Error("Ожидалась ошибка при некорректном формате ID")
 	}
+
+	// Тест 4: Повторное удаление цитаты из корзины
+	req = httptest.NewRequest(http.MethodDelete, "/quotes/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	err = services.Delete(s, log, req)
+	if err == nil {
+		t.Error("Ожидалась ошибка при удалении цитаты из корзины")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	log, err := logger.New(logger.Options{Outputs: []string{filepath.Join(t.TempDir(), "log.log")}})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage("temp_JSON.json", log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	defer os.Remove("temp_JSON.json")
+
+	quote := s.Add(context.Background(), storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "")
+	if err = s.DeleteQuoteID(context.Background(), quote.ID, 0, ""); err != nil {
+		t.Fatalf("DeleteQuoteID вернула ошибку: %v", err)
+	}
+
+	// Тест 1: Восстановление цитаты из корзины
+	req := httptest.NewRequest(http.MethodPost, "/trash/1/restore", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	restored, err := services.Restore(s, log, req)
+	if err != nil {
+		t.Fatalf("Restore вернула ошибку: %v", err)
+	}
+	if restored.DeletedAt != nil {
+		t.Errorf("Восстановленная цитата все еще помечена удаленной: %+v", restored)
+	}
+	if _, err = s.GetQuote(context.Background(), quote.ID); err != nil {
+		t.Errorf("Восстановленная цитата недоступна: %v", err)
+	}
+
+	// Тест 2: Очистка корзины по сроку хранения
+	if err = s.DeleteQuoteID(context.Background(), quote.ID, 0, ""); err != nil {
+		t.Fatalf("DeleteQuoteID вернула ошибку: %v", err)
+	}
+	if purged := s.PurgeTrash(context.Background(), time.Hour); purged != 0 {
+		t.Errorf("Ожидалось 0 удаленных цитат, получено: %d", purged)
+	}
+	if purged := s.PurgeTrash(context.Background(), 0); purged != 1 {
+		t.Errorf("Ожидалась 1 удаленная цитата, получено: %d", purged)
+	}
+	if len(s.Quotes) != 0 {
+		t.Errorf("Ожидалось пустое хранилище после очистки корзины, получено: %+v", s.Quotes)
+	}
+}
+
+func TestUpdateIfMatch(t *testing.T) {
+	log, err := logger.New(logger.Options{Outputs: []string{filepath.Join(t.TempDir(), "log.log")}})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage("temp_JSON.json", log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	defer os.Remove("temp_JSON.json")
+
+	quote := s.Add(context.Background(), storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "")
+	etag := services.ETag(quote)
+
+	newRequest := func(method, body, ifMatch string) *http.Request {
+		req := httptest.NewRequest(method, "/quotes/1", bytes.NewBufferString(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		return mux.SetURLVars(req, map[string]string{"id": "1"})
+	}
+
+	// Тест 1: Изменение с актуальным ETag
+	updated, err := services.Update(s, nil, false, log, newRequest(http.MethodPut, `{"quote":"Quote 2","author":"Author 1"}`, etag))
+	if err != nil {
+		t.Fatalf("Update вернула ошибку: %v", err)
+	}
+	if updated.Version != quote.Version+1 {
+		t.Errorf("Ожидалась версия %d, получено: %d", quote.Version+1, updated.Version)
+	}
+
+	// Тест 2: Изменение с устаревшим ETag
+	_, err = services.Update(s, nil, false, log, newRequest(http.MethodPut, `{"quote":"Quote 3","author":"Author 1"}`, etag))
+	if !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("Ожидалась ошибка несовпадения версии, получено: %v", err)
+	}
+
+	// Тест 3: Частичное изменение без If-Match
+	patched, err := services.Patch(s, nil, false, log, newRequest(http.MethodPatch, `{"author":"Author 2"}`, ""))
+	if err != nil {
+		t.Fatalf("Patch вернула ошибку: %v", err)
+	}
+	if patched.Quote != "Quote 2" || patched.Author != "Author 2" {
+		t.Errorf("Некорректная цитата после частичного изменения: %+v", patched)
+	}
+
+	// Тест 4: Удаление с устаревшим ETag
+	err = services.Delete(s, log, newRequest(http.MethodDelete, "", services.ETag(updated)))
+	if !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("Ожидалась ошибка несовпадения версии, получено: %v", err)
+	}
+
+	// Тест 5: Сравнение ETag для If-None-Match
+	if !services.MatchesETag(`W/"1", `+services.ETag(patched), services.ETag(patched)) {
+		t.Error("Ожидалось совпадение ETag")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	s, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	s.Add(context.Background(), storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "")
+
+	h := services.Health{Version: "test", StartedAt: time.Now().Add(-time.Minute), JSONPath: path}
+
+	// Тест 1: Сервис готов до первого сохранения
+	if readiness := services.CheckReadiness(s, h, log); !readiness.Ready {
+		t.Errorf("Ожидалась готовность, получено: %+v", readiness)
+	}
+
+	// Тест 2: Успешное сохранение отражается в статусе
+	if err = s.Save(context.Background(), path, log); err != nil {
+		t.Fatalf("Save вернула ошибку: %v", err)
+	}
+	status := services.GetStatus(s, h, log)
+	if status.Version != "test" || status.Storage.Quotes != 1 || status.Storage.LastSavedAt == nil || !status.Ready {
+		t.Errorf("Некорректный статус: %+v", status)
+	}
+
+	// Тест 3: Неудачное сохранение снимает готовность
+	if err = s.Save(context.Background(), filepath.Join(path, "missing", "quotes.json"), log); err == nil {
+		t.Fatalf("Ожидалась ошибка сохранения")
+	}
+	readiness := services.CheckReadiness(s, h, log)
+	if readiness.Ready || readiness.Checks[1].OK {
+		t.Errorf("Ожидалась неготовность из-за ошибки сохранения, получено: %+v", readiness)
+	}
+	if status = services.GetStatus(s, h, log); status.Storage.LastSaveError == "" || status.Storage.LastSavedAt == nil {
+		t.Errorf("Ожидалась ошибка сохранения в статусе, получено: %+v", status.Storage)
+	}
+
+	// Тест 4: Недоступный файл хранилища снимает готовность
+	if err = os.Remove(path); err != nil {
+		t.Fatalf("Не удалось удалить файл хранилища: %v", err)
+	}
+	if readiness = services.CheckReadiness(s, h, log); readiness.Checks[0].OK {
+		t.Errorf("Ожидалась ошибка проверки storage, получено: %+v", readiness)
+	}
+
+	// Тест 5: Изменения сохраняются в фоне, и готовность возвращается
+	keysPath := filepath.Join(t.TempDir(), "keys.json")
+	keys, err := auth.OpenKeyStore(keysPath)
+	if err != nil {
+		t.Fatalf("OpenKeyStore вернула ошибку: %v", err)
+	}
+	_, raw, err := keys.Create("ci", auth.RoleReader)
+	if err != nil {
+		t.Fatalf("Create вернула ошибку: %v", err)
+	}
+	if _, err = keys.Authenticate(raw); err != nil {
+		t.Fatalf("Authenticate вернула ошибку: %v", err)
+	}
+
+	s.Add(context.Background(), storage.Quote{Quote: "Quote 2", Author: "Author 2"}, "")
+	stop := make(chan struct{})
+	defer close(stop)
+	go services.RunAutosave(s, keys, path, log, func() time.Duration { return 10 * time.Millisecond }, stop)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !services.CheckReadiness(s, h, log).Ready && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if readiness = services.CheckReadiness(s, h, log); !readiness.Ready {
+		t.Errorf("Ожидалась готовность после фонового сохранения, получено: %+v", readiness)
+	}
+	if saved, err := s.SaveChanges(context.Background(), path, log); saved || err != nil {
+		t.Errorf("Сохранение без изменений не ожидалось, получено: %v, %v", saved, err)
+	}
+
+	// Тест 6: Отметка использования ключа сохраняется в фоне, а не только при остановке
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		reopened, err := auth.OpenKeyStore(keysPath)
+		if err != nil {
+			t.Fatalf("OpenKeyStore вернула ошибку: %v", err)
+		}
+		if list := reopened.List(); len(list) == 1 && list[0].LastUsedAt != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Отметка использования ключа не сохранена: %+v", reopened.List())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUpdateOwnership(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage(filepath.Join(t.TempDir(), "quotes.json"), log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+
+	alice := auth.Principal{Subject: "alice", Role: auth.RoleContributor}
+	editor := auth.Principal{Subject: "carol", Role: auth.RoleEditor}
+
+	newRequest := func(principal auth.Principal, body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewBufferString(body))
+		return req.WithContext(auth.ContextWithPrincipal(req.Context(), principal))
+	}
+
+	// Тест 1: Автором изменения записывается аутентифицированный клиент
+	if _, err = services.Add(s, nil, false, newRequest(alice, `{"quote":"Quote 1","author":"Author 1"}`), log); err != nil {
+		t.Fatalf("Add вернула ошибку: %v", err)
+	}
+	if _, err = services.Add(s, nil, false, newRequest(editor, `{"quote":"Quote 2","author":"Author 2"}`), log); err != nil {
+		t.Fatalf("Add вернула ошибку: %v", err)
+	}
+	if s.Quotes[0].SubmittedBy != "alice" || s.Quotes[0].Owner != "sub:alice" {
+		t.Errorf("Ожидался submitted_by alice и owner sub:alice, получено: %q, %q", s.Quotes[0].SubmittedBy, s.Quotes[0].Owner)
+	}
+
+	update := func(principal auth.Principal, id string) error {
+		req := mux.SetURLVars(newRequest(principal, `{"quote":"Changed","author":"Author"}`), map[string]string{"id": id})
+		_, err := services.Update(s, nil, false, log, req)
+		return err
+	}
+
+	// Тест 2: Участник изменяет свою цитату, но не чужую
+	if err = update(alice, "1"); err != nil {
+		t.Errorf("Участник должен изменять свою цитату, получено: %v", err)
+	}
+	var forbidden *auth.ForbiddenError
+	if err = update(alice, "2"); !errors.As(err, &forbidden) {
+		t.Errorf("Ожидался отказ в доступе, получено: %v", err)
+	}
+
+	// Тест 3: Редактор изменяет любую цитату
+	if err = update(editor, "1"); err != nil {
+		t.Errorf("Редактор должен изменять любую цитату, получено: %v", err)
+	}
+
+	// Тест 4: Ключ с тем же именем не становится владельцем
+	namesake := auth.Principal{Subject: "alice", Role: auth.RoleContributor, Method: auth.MethodAPIKey, KeyID: "k1"}
+	if err = update(namesake, "1"); !errors.As(err, &forbidden) {
+		t.Errorf("Ожидался отказ ключу с именем владельца, получено: %v", err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage(filepath.Join(t.TempDir(), "quotes.json"), log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	s.Add(context.Background(), storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "test")
+
+	newRequest := func(body string) *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(body))
+	}
+
+	// Тест 1: Регистрация проверяет имя и длину пароля
+	for _, body := range []string{`{"username":"al","password":"long enough"}`, `{"username":"alice","password":"short"}`, `{"username":"al ice","password":"long enough"}`} {
+		if _, err = services.Register(s, log, newRequest(body)); err == nil {
+			t.Errorf("Ожидалась ошибка регистрации для %s", body)
+		}
+	}
+	user, err := services.Register(s, log, newRequest(`{"username":"alice","password":"long enough"}`))
+	if err != nil {
+		t.Fatalf("Register вернула ошибку: %v", err)
+	}
+
+	// Тест 2: Вход с неверным паролем или именем отклоняется одинаково
+	for _, body := range []string{`{"username":"alice","password":"wrong password"}`, `{"username":"nobody","password":"long enough"}`} {
+		if _, err = services.Login(s, time.Hour, log, newRequest(body)); !errors.Is(err, services.ErrInvalidCredentials) {
+			t.Errorf("Ожидалась ошибка ErrInvalidCredentials, получено: %v", err)
+		}
+	}
+
+	// Тест 3: Успешный вход открывает сессию
+	login, err := services.Login(s, time.Hour, log, newRequest(`{"username":"alice","password":"long enough"}`))
+	if err != nil {
+		t.Fatalf("Login вернула ошибку: %v", err)
+	}
+	hash, ok := auth.SessionHash(login.Token)
+	if !ok || login.User.ID != user.ID {
+		t.Fatalf("Неожиданный ответ на вход: %+v", login)
+	}
+	if _, err = s.Session(context.Background(), hash); err != nil {
+		t.Errorf("Сессия не найдена: %v", err)
+	}
+
+	principal := auth.Principal{Subject: user.Username, Role: auth.RoleReader, Method: auth.MethodSession, UserID: user.ID, SessionID: hash}
+	userRequest := func(vars map[string]string) *http.Request {
+		req := httptest.NewRequest(http.MethodPut, "/me", nil)
+		return mux.SetURLVars(req.WithContext(auth.ContextWithPrincipal(req.Context(), principal)), vars)
+	}
+
+	// Тест 4: Избранное пользователя
+	if err = services.AddFavorite(s, log, userRequest(map[string]string{"id": "1"})); err != nil {
+		t.Fatalf("AddFavorite вернула ошибку: %v", err)
+	}
+	if favorites, err := services.GetFavorites(s, log, userRequest(nil)); err != nil || len(favorites) != 1 {
+		t.Errorf("Ожидалась одна избранная цитата, получено: %+v, %v", favorites, err)
+	}
+
+	// Тест 5: После выхода сессия недействительна
+	if err = services.Logout(s, log, userRequest(nil)); err != nil {
+		t.Fatalf("Logout вернула ошибку: %v", err)
+	}
+	if _, err = s.Session(context.Background(), hash); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("Ожидалась ошибка ErrSessionNotFound, получено: %v", err)
+	}
+}
+
+func TestLikes(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage(filepath.Join(t.TempDir(), "quotes.json"), log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	s.Add(context.Background(), storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "test")
+	s.Add(context.Background(), storage.Quote{Quote: "Quote 2", Author: "Author 2"}, "test")
+
+	like := func(principal *auth.Principal, id string) storage.QuoteStore {
+		req := httptest.NewRequest(http.MethodPost, "/quotes/"+id+"/like", nil)
+		if principal != nil {
+			req = req.WithContext(auth.ContextWithPrincipal(req.Context(), *principal))
+		}
+		quote, err := services.Like(s, log, mux.SetURLVars(req, map[string]string{"id": id}))
+		if err != nil {
+			t.Fatalf("Like вернула ошибку: %v", err)
+		}
+		return quote
+	}
+
+	alice := &auth.Principal{Subject: "alice", Role: auth.RoleReader, Method: auth.MethodSession, UserID: 1}
+	key := &auth.Principal{Subject: "alice", Role: auth.RoleContributor, Method: auth.MethodAPIKey, KeyID: "k1"}
+
+	// Тест 1: Лайки учитываются по клиенту, а не по имени
+	before := services.ETag(like(alice, "2"))
+	like(alice, "2")
+	like(nil, "2")
+	quote := like(key, "2")
+	if quote.Likes != 3 {
+		t.Errorf("Ожидалось 3 лайка, получено: %d", quote.Likes)
+	}
+
+	// Тест 2: Лайк меняет ETag, но не версию для If-Match
+	if after := services.ETag(quote); after == before || quote.Version != 1 {
+		t.Errorf("ETag должен измениться без смены версии: %s, %s, версия %d", before, after, quote.Version)
+	}
+
+	// Тест 3: Сортировка по популярности
+	req := httptest.NewRequest(http.MethodGet, "/quotes?sort=popular", nil)
+	quotes, err := services.GetQuotes(s, log, req)
+	if err != nil || len(quotes) != 2 || quotes[0].ID != 2 {
+		t.Errorf("Ожидалась первой цитата 2, получено: %+v, %v", quotes, err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/quotes?sort=unknown", nil)
+	if _, err = services.GetQuotes(s, log, req); err == nil {
+		t.Error("Ожидалась ошибка для неизвестной сортировки")
+	}
+
+	// Тест 4: Анонимный клиент определяется по адресу, а не по заголовку X-User
+	for _, user := range []string{"mallory", "eve"} {
+		req = httptest.NewRequest(http.MethodPost, "/quotes/2/like", nil)
+		req.Header.Set("X-User", user)
+		if actor := services.Actor(req); actor != "192.0.2.1" {
+			t.Errorf("Ожидался адрес клиента, получено: %q", actor)
+		}
+		if quote, err = services.Like(s, log, mux.SetURLVars(req, map[string]string{"id": "2"})); err != nil || quote.Likes != 3 {
+			t.Errorf("Повторный анонимный лайк не должен учитываться, получено: %d, %v", quote.Likes, err)
+		}
+	}
+}
+
+func TestModerated(t *testing.T) {
+	newRequest := func(role auth.Role) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/quotes", nil)
+		return req.WithContext(auth.ContextWithPrincipal(req.Context(), auth.Principal{Subject: "alice", Role: role}))
+	}
+
+	// Тест 1: Без модерации цитаты публикуются сразу
+	if services.Moderated(newRequest(auth.RoleContributor), false) {
+		t.Error("При выключенной модерации цитата не должна попадать в очередь")
+	}
+
+	// Тест 2: Цитаты участников попадают в очередь, редакторов - нет
+	if !services.Moderated(newRequest(auth.RoleContributor), true) {
+		t.Error("Цитата участника должна попадать в очередь")
+	}
+	if services.Moderated(newRequest(auth.RoleEditor), true) {
+		t.Error("Цитата редактора должна публиковаться сразу")
+	}
+}
+
+func TestReport(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage(filepath.Join(t.TempDir(), "quotes.json"), log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	s.Add(context.Background(), storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "test")
+
+	report := func(principal auth.Principal, body string) (services.ReportResult, error) {
+		req := httptest.NewRequest(http.MethodPost, "/quotes/1/report", bytes.NewBufferString(body))
+		req = req.WithContext(auth.ContextWithPrincipal(req.Context(), principal))
+		return services.Report(s, 2, log, mux.SetURLVars(req, map[string]string{"id": "1"}))
+	}
+
+	alice := auth.Principal{Subject: "alice", Role: auth.RoleReader, Method: auth.MethodSession, UserID: 1}
+	key := auth.Principal{Subject: "bob", Role: auth.RoleContributor, Method: auth.MethodAPIKey, KeyID: "k1"}
+
+	// Тест 1: Жалоба принимается, цитата пока видна
+	result, err := report(alice, `{"category": "misattributed", "comment": "Это сказал другой автор"}`)
+	if err != nil || result.Hidden || result.Category != storage.ReportMisattributed {
+		t.Fatalf("Ожидалась принятая жалоба, получено: %+v, %v", result, err)
+	}
+
+	// Тест 2: Повторная жалоба того же пользователя отклоняется
+	if _, err = report(alice, `{"category": "spam"}`); !errors.Is(err, storage.ErrDuplicateReport) {
+		t.Errorf("Ожидалась ошибка ErrDuplicateReport, получено: %v", err)
+	}
+
+	// Тест 3: Жалоба другого клиента скрывает цитату
+	if result, err = report(key, `{"category": "offensive"}`); err != nil || !result.Hidden {
+		t.Errorf("Ожидалось скрытие цитаты, получено: %+v, %v", result, err)
+	}
+
+	// Тест 4: Неизвестный статус в списке жалоб
+	req := httptest.NewRequest(http.MethodGet, "/admin/reports?status=closed", nil)
+	if _, err = services.GetReports(s, log, req); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного статуса")
+	}
+
+	// Тест 5: Анонимные жалобы учитываются по адресу клиента
+	s.Add(context.Background(), storage.Quote{Quote: "Quote 2", Author: "Author 2"}, "test")
+	anonymous := func(addr, user string) error {
+		req := httptest.NewRequest(http.MethodPost, "/quotes/2/report", bytes.NewBufferString(`{"category": "spam"}`))
+		req.RemoteAddr = addr
+		req.Header.Set("X-User", user)
+		_, err := services.Report(s, 2, log, mux.SetURLVars(req, map[string]string{"id": "2"}))
+		return err
+	}
+	if err = anonymous("192.0.2.1:1234", "mallory"); err != nil {
+		t.Fatalf("Ожидалась принятая анонимная жалоба, получено: %v", err)
+	}
+	if err = anonymous("192.0.2.1:5678", "eve"); !errors.Is(err, storage.ErrDuplicateReport) {
+		t.Errorf("Ожидалась ошибка ErrDuplicateReport для того же адреса, получено: %v", err)
+	}
+	if quote, err := s.GetQuote(context.Background(), 2); err != nil || quote.Hidden {
+		t.Errorf("Один анонимный клиент не должен скрывать цитату, получено: %+v, %v", quote, err)
+	}
+
+	// Тест 6: Адреса IPv6 из одной сети /64 считаются одним клиентом
+	if err = anonymous("[2001:db8:1:2::1]:1234", ""); err != nil {
+		t.Fatalf("Ожидалась принятая анонимная жалоба, получено: %v", err)
+	}
+	if err = anonymous("[2001:db8:1:2:ffff::9]:1234", ""); !errors.Is(err, storage.ErrDuplicateReport) {
+		t.Errorf("Ожидалась ошибка ErrDuplicateReport для той же сети /64, получено: %v", err)
+	}
+
+	// Тест 7: Анонимные жалобы с разных адресов не скрывают цитату
+	if err = anonymous("198.51.100.7:1234", ""); err != nil {
+		t.Fatalf("Ожидалась принятая анонимная жалоба, получено: %v", err)
+	}
+	if quote, err := s.GetQuote(context.Background(), 2); err != nil || quote.Hidden {
+		t.Errorf("Анонимные жалобы не должны скрывать цитату, получено: %+v, %v", quote, err)
+	}
+	if reports := s.Reports(context.Background(), "open"); len(reports) != 2 || reports[0].QuoteID != 2 || reports[0].Open != 3 {
+		t.Errorf("Ожидались 3 открытые жалобы на цитату 2, получено: %+v", reports)
+	}
+}
+
+func TestAddFilter(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage(filepath.Join(t.TempDir(), "quotes.json"), log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+
+	var filters filter.Chain
+	filters.Use(filter.NewWords("words_ru", "ru", []string{"дурак"}), filter.ActionMask)
+	filters.Use(filter.NewLinks("links"), filter.ActionFlag)
+	filters.Use(filter.NewWords("words_en", "en", []string{"casino"}), filter.ActionReject)
+
+	alice := auth.Principal{Subject: "alice", Role: auth.RoleContributor}
+	editor := auth.Principal{Subject: "carol", Role: auth.RoleEditor}
+
+	add := func(principal auth.Principal, body string) (storage.QuoteStore, error) {
+		req := httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewBufferString(body))
+		req = req.WithContext(auth.ContextWithPrincipal(req.Context(), principal))
+		return services.Add(s, &filters, false, req, log)
+	}
+
+	// Тест 1: Найденные слова маскируются
+	quote, err := add(alice, `{"quote":"Сам дурак","author":"Автор"}`)
+	if err != nil || quote.Quote != "Сам *****" || quote.Status != "" {
+		t.Errorf("Ожидалась опубликованная цитата с маской, получено: %+v, %v", quote, err)
+	}
+
+	// Тест 2: Помеченная цитата участника уходит на модерацию
+	quote, err = add(alice, `{"quote":"Все цитаты на example.com","author":"Автор"}`)
+	if err != nil || quote.Status != storage.StatusPending || len(quote.Flags) != 1 || quote.Flags[0] != "links" {
+		t.Errorf("Ожидалась цитата на модерации, получено: %+v, %v", quote, err)
+	}
+
+	// Тест 3: Цитата модератора публикуется сразу
+	if quote, err = add(editor, `{"quote":"Все цитаты на example.com","author":"Автор"}`); err != nil || quote.Status != "" {
+		t.Errorf("Ожидалась опубликованная цитата, получено: %+v, %v", quote, err)
+	}
+
+	// Тест 4: Отклоненная цитата не сохраняется
+	var rejected *filter.RejectedError
+	if _, err = add(editor, `{"quote":"Best casinos","author":"Spam"}`); !errors.As(err, &rejected) {
+		t.Errorf("Ожидалась ошибка RejectedError, получено: %v", err)
+	}
+
+	// Тест 5: Помеченное изменение участника отклоняется
+	req := httptest.NewRequest(http.MethodPut, "/quotes/1", bytes.NewBufferString(`{"quote":"Теперь на example.com","author":"Автор"}`))
+	req = mux.SetURLVars(req.WithContext(auth.ContextWithPrincipal(req.Context(), alice)), map[string]string{"id": "1"})
+	if _, err = services.Update(s, &filters, false, log, req); !errors.As(err, &rejected) {
+		t.Errorf("Ожидалась ошибка RejectedError, получено: %v", err)
+	}
+	if len(s.Quotes) != 3 {
+		t.Errorf("Ожидалось 3 цитаты в хранилище, получено: %d", len(s.Quotes))
+	}
+
+	update := func(principal auth.Principal, id, body string) (storage.QuoteStore, error) {
+		req := httptest.NewRequest(http.MethodPut, "/quotes/"+id, bytes.NewBufferString(body))
+		req = mux.SetURLVars(req.WithContext(auth.ContextWithPrincipal(req.Context(), principal)), map[string]string{"id": id})
+		return services.Update(s, &filters, services.Moderated(req, true), log, req)
+	}
+
+	// Тест 6: При модерации изменение участника возвращает цитату в очередь
+	quote, err = update(alice, "1", `{"quote":"Теперь на example.com","author":"Автор"}`)
+	if err != nil || quote.Status != storage.StatusPending || len(quote.Flags) != 1 || quote.Flags[0] != "links" {
+		t.Fatalf("Ожидалась цитата на модерации, получено: %+v, %v", quote, err)
+	}
+	if _, err = s.GetQuote(context.Background(), 1); !errors.Is(err, storage.ErrQuoteNotFound) {
+		t.Errorf("Непроверенное изменение не должно быть видно, получено: %v", err)
+	}
+	if history, _ := s.GetHistory(context.Background(), 1, true); history[len(history)-1].Action != storage.ActionSubmit {
+		t.Errorf("Ожидалась ревизия submit, получено: %+v", history)
+	}
+
+	// Тест 7: Изменение модератора при модерации публикуется сразу
+	if quote, err = update(editor, "3", `{"quote":"Другой текст","author":"Автор"}`); err != nil || quote.Status != "" {
+		t.Errorf("Ожидалась опубликованная цитата, получено: %+v, %v", quote, err)
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage(filepath.Join(t.TempDir(), "quotes.json"), log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+
+	editor := auth.Principal{Subject: "carol", Role: auth.RoleEditor}
+	newRequest := func(method, target, body string) *http.Request {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		return req.WithContext(auth.ContextWithPrincipal(req.Context(), editor))
+	}
+
+	// Тест 1: Код языка приводится к каноническому виду, неверный отклоняется
+	quote, err := services.Add(s, nil, false, newRequest(http.MethodPost, "/quotes", `{"quote":"Цитата","author":"Автор","language":"RU"}`), log)
+	if err != nil || quote.Language != "ru" {
+		t.Fatalf("Ожидался язык ru, получено: %+v, %v", quote, err)
+	}
+	if _, err = services.Add(s, nil, false, newRequest(http.MethodPost, "/quotes", `{"quote":"Q","author":"A","language":"english!"}`), log); err == nil {
+		t.Error("Ожидалась ошибка для неверного кода языка")
+	}
+
+	translate := func(lang, body string) (storage.QuoteStore, error) {
+		req := newRequest(http.MethodPut, "/quotes/1/translations/"+lang, body)
+		return services.SetTranslation(s, nil, false, log, mux.SetURLVars(req, map[string]string{"id": "1", "lang": lang}))
+	}
+
+	// Тест 2: Переводы добавляются по коду языка
+	if _, err = translate("EN", `{"quote":"Quote","author":"Author"}`); err != nil {
+		t.Fatalf("SetTranslation вернула ошибку: %v", err)
+	}
+	if _, err = translate("pt-br", `{"quote":"Citação"}`); err != nil {
+		t.Fatalf("SetTranslation вернула ошибку: %v", err)
+	}
+	if _, err = translate("de", `{"quote":""}`); err == nil {
+		t.Error("Ожидалась ошибка для пустого перевода")
+	}
+
+	get := func(acceptLanguage string) storage.QuoteStore {
+		req := httptest.NewRequest(http.MethodGet, "/quotes", nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		quotes, err := services.GetQuotes(s, log, req)
+		if err != nil || len(quotes) != 1 {
+			t.Fatalf("GetQuotes вернула: %+v, %v", quotes, err)
+		}
+		return quotes[0]
+	}
+
+	// Тест 3: Перевод выбирается по Accept-Language
+	tests := []struct {
+		acceptLanguage string
+		quote          string
+		author         string
+		translated     string
+	}{
+		{"", "Цитата", "Автор", ""},
+		{"en-US,en;q=0.9", "Quote", "Author", "en"},
+		{"de, en;q=0.5", "Quote", "Author", "en"},
+		{"pt-BR", "Citação", "Автор", "pt-BR"},
+		{"ru, en;q=0.8", "Цитата", "Автор", ""},
+		{"ja", "Цитата", "Автор", ""},
+	}
+	for _, tt := range tests {
+		got := get(tt.acceptLanguage)
+		if got.Quote != tt.quote || got.Author != tt.author || got.Translated != tt.translated {
+			t.Errorf("Accept-Language %q: ожидалось %q, %q, %q, получено: %q, %q, %q",
+				tt.acceptLanguage, tt.quote, tt.author, tt.translated, got.Quote, got.Author, got.Translated)
+		}
+	}
+
+	// Тест 4: Смена Accept-Language не дает 304 по ETag другого языка
+	original := services.ListETag([]storage.QuoteStore{get("ru")})
+	if services.MatchesETag(original, services.ListETag([]storage.QuoteStore{get("en")})) {
+		t.Error("ETag списка должен зависеть от языка перевода")
+	}
+	if !services.MatchesETag(original, services.ListETag([]storage.QuoteStore{get("de")})) {
+		t.Error("Одинаковое представление должно давать одинаковый ETag")
+	}
+	if en := get("en"); services.ETag(en) == services.ETag(get("ru")) {
+		t.Errorf("ETag цитаты должен зависеть от языка перевода: %s", services.ETag(en))
+	}
+
+	// Тест 5: Удаление перевода
+	req := newRequest(http.MethodDelete, "/quotes/1/translations/en", "")
+	if err = services.DeleteTranslation(s, log, mux.SetURLVars(req, map[string]string{"id": "1", "lang": "en"})); err != nil {
+		t.Errorf("DeleteTranslation вернула ошибку: %v", err)
+	}
+	if got := get("en"); got.Translated != "" {
+		t.Errorf("Удаленный перевод не должен показываться: %+v", got)
+	}
+}
+
+func TestHistoryVisibility(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage(filepath.Join(t.TempDir(), "quotes.json"), log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	ctx := context.Background()
+
+	public := s.Add(ctx, storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "alice")
+	pending := s.Submit(ctx, storage.Quote{Quote: "Quote 2", Author: "Author 2"}, "bob")
+	trashed := s.Add(ctx, storage.Quote{Quote: "Quote 3", Author: "Author 3"}, "alice")
+	if err = s.DeleteQuoteID(ctx, trashed.ID, 0, "carol"); err != nil {
+		t.Fatalf("DeleteQuoteID вернула ошибку: %v", err)
+	}
+
+	history := func(principal *auth.Principal, id int) error {
+		req := httptest.NewRequest(http.MethodGet, "/quotes/"+strconv.Itoa(id)+"/history", nil)
+		if principal != nil {
+			req = req.WithContext(auth.ContextWithPrincipal(req.Context(), *principal))
+		}
+		_, err := services.GetHistory(s, log, mux.SetURLVars(req, map[string]string{"id": strconv.Itoa(id)}))
+		return err
+	}
+
+	// Тест 1: История видимой цитаты доступна всем
+	if err = history(nil, public.ID); err != nil {
+		t.Errorf("Ожидалась история видимой цитаты, получено: %v", err)
+	}
+
+	// Тест 2: История скрытых цитат не видна читателям и участникам
+	contributor := &auth.Principal{Subject: "bob", Role: auth.RoleContributor}
+	for _, principal := range []*auth.Principal{nil, contributor} {
+		for _, id := range []int{pending.ID, trashed.ID} {
+			if err = history(principal, id); !errors.Is(err, storage.ErrQuoteNotFound) {
+				t.Errorf("Ожидалась ошибка ErrQuoteNotFound для цитаты %d, получено: %v", id, err)
+			}
+		}
+	}
+
+	// Тест 3: Редактор видит историю скрытых цитат
+	editor := &auth.Principal{Subject: "carol", Role: auth.RoleEditor}
+	for _, id := range []int{pending.ID, trashed.ID} {
+		if err = history(editor, id); err != nil {
+			t.Errorf("Ожидалась история цитаты %d для редактора, получено: %v", id, err)
+		}
+	}
 }
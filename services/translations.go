@@ -0,0 +1,190 @@
+package services
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"quotes/filter"
+	"quotes/logger"
+	"quotes/storage"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/text/language"
+)
+
+// normalizeLanguage приводит код языка к каноническому виду BCP 47:
+// "EN" -> "en", "pt-br" -> "pt-BR". Пустой код остается пустым.
+func normalizeLanguage(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	tag, err := language.Parse(value)
+	if err != nil || tag == language.Und {
+		return "", fmt.Errorf("Неверный код языка: %q", value)
+	}
+	return tag.String(), nil
+}
+
+// acceptLanguages возвращает языки из заголовка Accept-Language в порядке
+// предпочтения или nil, если заголовка нет или он некорректен.
+func acceptLanguages(r *http.Request) []language.Tag {
+	header := r.Header.Get("Accept-Language")
+	if header == "" {
+		return nil
+	}
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil {
+		return nil
+	}
+	return tags
+}
+
+// localize подставляет в цитату перевод на наиболее подходящий из языков
+// preferred и отмечает его в поле Translated. Если оригинал подходит не
+// хуже переводов или подходящего перевода нет, цитата возвращается без
+// изменений.
+func localize(quote storage.QuoteStore, preferred []language.Tag) storage.QuoteStore {
+	if len(preferred) == 0 || len(quote.Translations) == 0 {
+		return quote
+	}
+
+	original := language.Und
+	if quote.Language != "" {
+		original = language.Make(quote.Language)
+	}
+	langs := append([]string{""}, slices.Sorted(maps.Keys(quote.Translations))...)
+	supported := []language.Tag{original}
+	for _, lang := range langs[1:] {
+		supported = append(supported, language.Make(lang))
+	}
+
+	_, index, confidence := language.NewMatcher(supported).Match(preferred...)
+	if confidence == language.No || langs[index] == "" {
+		return quote
+	}
+
+	translation := quote.Translations[langs[index]]
+	quote.Quote = translation.Quote
+	if translation.Author != "" {
+		quote.Author = translation.Author
+	}
+	quote.Translated = langs[index]
+
+	return quote
+}
+
+func GetTranslations(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (map[string]storage.Translation, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	quote, err := s.GetQuote(r.Context(), id)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при получении переводов: %w", err)
+	}
+
+	translations := quote.Translations
+	if translations == nil {
+		translations = map[string]storage.Translation{}
+	}
+
+	log.Info("Получение переводов прошло успешно", "id", id, "found", len(translations))
+
+	return translations, nil
+}
+
+// SetTranslation добавляет или заменяет перевод цитаты. Перевод проходит
+// через фильтр содержимого и модерацию как изменение цитаты, см. Update.
+func SetTranslation(s *storage.JSONStorage, filters *filter.Chain, moderated bool, log *logger.Logger, r *http.Request) (storage.QuoteStore, error) {
+	defer r.Body.Close()
+
+	id, lang, err := translationVars(r)
+	if err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	var request struct {
+		Quote  string `json:"quote"`
+		Author string `json:"author"`
+	}
+	if err = decodeJSON(r, &request); err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+	if strings.TrimSpace(request.Quote) == "" {
+		return storage.QuoteStore{}, fmt.Errorf("Текст перевода обязателен")
+	}
+
+	if err = authorizeUpdate(s, r, id); err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	version, err := ifMatchVersion(s, r, id)
+	if err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	text := storage.Quote{Quote: request.Quote, Author: request.Author}
+	flags, err := filterUpdate(filters, r, &text, moderated, log)
+	if err != nil {
+		return storage.QuoteStore{}, err
+	}
+
+	translation := storage.Translation{Quote: text.Quote, Author: text.Author}
+	var updated storage.QuoteStore
+	if moderated {
+		updated, err = s.SubmitTranslation(r.Context(), id, lang, translation, version, Actor(r), flags...)
+	} else {
+		updated, err = s.SetTranslation(r.Context(), id, lang, translation, version, Actor(r))
+	}
+	if err != nil {
+		return storage.QuoteStore{}, fmt.Errorf("Ошибка при сохранении перевода: %w", err)
+	}
+
+	log.Info("Перевод цитаты сохранен", "id", id, "lang", lang, "version", updated.Version, "status", updated.Status)
+
+	return updated, nil
+}
+
+func DeleteTranslation(s *storage.JSONStorage, log *logger.Logger, r *http.Request) error {
+	id, lang, err := translationVars(r)
+	if err != nil {
+		return err
+	}
+
+	if err = authorizeUpdate(s, r, id); err != nil {
+		return err
+	}
+
+	version, err := ifMatchVersion(s, r, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.DeleteTranslation(r.Context(), id, lang, version, Actor(r)); err != nil {
+		return fmt.Errorf("Ошибка при удалении перевода: %w", err)
+	}
+
+	log.Info("Перевод цитаты удален", "id", id, "lang", lang)
+
+	return nil
+}
+
+func translationVars(r *http.Request) (int, string, error) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, "", fmt.Errorf("Неверный формат ID: %v", err)
+	}
+	lang, err := normalizeLanguage(vars["lang"])
+	if err != nil || lang == "" {
+		return 0, "", fmt.Errorf("Неверный код языка: %q", vars["lang"])
+	}
+	return id, lang, nil
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"quotes/auth"
+	"quotes/logger"
+	"quotes/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+var ErrInvalidCredentials = errors.New("Неверное имя пользователя или пароль")
+
+const (
+	minPasswordLength   = 8
+	maxPasswordLength   = 256
+	maxCollectionLength = 100
+)
+
+// dummyHash сверяется с паролем при входе несуществующего пользователя,
+// чтобы по времени ответа нельзя было узнать, занято ли имя.
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("dummy password")
+	return hash
+})
+
+// LoginResult ответ на вход пользователя. Token показывается только один раз.
+type LoginResult struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	User      storage.User `json:"user"`
+}
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func Register(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.User, error) {
+	defer r.Body.Close()
+
+	var request credentials
+	if err := decodeJSON(r, &request); err != nil {
+		return storage.User{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+	if err := validateUsername(request.Username); err != nil {
+		return storage.User{}, err
+	}
+	if n := utf8.RuneCountInString(request.Password); n < minPasswordLength || n > maxPasswordLength {
+		return storage.User{}, fmt.Errorf("Длина пароля должна быть от %d до %d символов", minPasswordLength, maxPasswordLength)
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		return storage.User{}, fmt.Errorf("Не удалось вычислить хеш пароля: %w", err)
+	}
+
+	user, err := s.Register(r.Context(), request.Username, hash)
+	if err != nil {
+		return storage.User{}, fmt.Errorf("Ошибка при регистрации пользователя: %w", err)
+	}
+
+	log.Info("Зарегистрирован пользователь", "user_id", user.ID, "username", user.Username)
+
+	return user, nil
+}
+
+// validateUsername допускает латинские буквы, цифры, точку, дефис и
+// подчеркивание, от 3 до 32 символов.
+func validateUsername(username string) error {
+	if len(username) < 3 || len(username) > 32 {
+		return errors.New("Длина имени пользователя должна быть от 3 до 32 символов")
+	}
+	for _, c := range username {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '-', c == '_':
+		default:
+			return fmt.Errorf("Недопустимый символ в имени пользователя: %q", c)
+		}
+	}
+	return nil
+}
+
+// Login проверяет имя и пароль и открывает сессию на ttl.
+func Login(s *storage.JSONStorage, ttl time.Duration, log *logger.Logger, r *http.Request) (LoginResult, error) {
+	defer r.Body.Close()
+
+	var request credentials
+	if err := decodeJSON(r, &request); err != nil {
+		return LoginResult{}, fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+
+	user, hash, err := s.Credentials(r.Context(), request.Username)
+	if err != nil {
+		hash = dummyHash()
+	}
+	ok, checkErr := auth.CheckPassword(hash, request.Password)
+	if checkErr != nil {
+		return LoginResult{}, fmt.Errorf("Ошибка при проверке пароля: %w", checkErr)
+	}
+	if err != nil || !ok {
+		log.Warn("Неудачная попытка входа", "username", request.Username)
+		return LoginResult{}, ErrInvalidCredentials
+	}
+
+	token, tokenHash, err := auth.NewSessionToken()
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("Не удалось создать токен сессии: %w", err)
+	}
+	session, err := s.CreateSession(r.Context(), user.ID, tokenHash, ttl)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("Ошибка при создании сессии: %w", err)
+	}
+
+	log.Info("Пользователь вошел", "user_id", user.ID, "username", user.Username)
+
+	return LoginResult{Token: token, ExpiresAt: session.ExpiresAt, User: user}, nil
+}
+
+func Logout(s *storage.JSONStorage, log *logger.Logger, r *http.Request) error {
+	principal, _ := auth.FromContext(r.Context())
+
+	if err := s.DeleteSession(r.Context(), principal.SessionID); err != nil {
+		return fmt.Errorf("Ошибка при выходе: %w", err)
+	}
+
+	log.Info("Пользователь вышел", "user_id", principal.UserID)
+
+	return nil
+}
+
+// currentUser возвращает ID пользователя запроса. Маршруты пользователя
+// закрыты middleware.RequireUser, поэтому ID всегда есть.
+func currentUser(r *http.Request) int {
+	principal, _ := auth.FromContext(r.Context())
+	return principal.UserID
+}
+
+func GetMe(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.User, error) {
+	user, err := s.GetUser(r.Context(), currentUser(r))
+	if err != nil {
+		return storage.User{}, fmt.Errorf("Ошибка при получении пользователя: %w", err)
+	}
+
+	log.Info("Получение пользователя прошло успешно", "user_id", user.ID)
+
+	return user, nil
+}
+
+func GetFavorites(s *storage.JSONStorage, log *logger.Logger, r *http.Request) ([]storage.QuoteStore, error) {
+	quotes, err := s.Favorites(r.Context(), currentUser(r))
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при получении избранного: %w", err)
+	}
+
+	log.Info("Получение избранного прошло успешно", "user_id", currentUser(r), "found", len(quotes))
+
+	return quotes, nil
+}
+
+func AddFavorite(s *storage.JSONStorage, log *logger.Logger, r *http.Request) error {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	if err = s.AddFavorite(r.Context(), currentUser(r), id); err != nil {
+		return fmt.Errorf("Ошибка при добавлении в избранное: %w", err)
+	}
+
+	log.Info("Цитата добавлена в избранное", "user_id", currentUser(r), "id", id)
+
+	return nil
+}
+
+func RemoveFavorite(s *storage.JSONStorage, log *logger.Logger, r *http.Request) error {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return fmt.Errorf("Неверный формат ID: %v", err)
+	}
+
+	if err = s.RemoveFavorite(r.Context(), currentUser(r), id); err != nil {
+		return fmt.Errorf("Ошибка при удалении из избранного: %w", err)
+	}
+
+	log.Info("Цитата удалена из избранного", "user_id", currentUser(r), "id", id)
+
+	return nil
+}
+
+func GetCollections(s *storage.JSONStorage, log *logger.Logger, r *http.Request) []storage.Collection {
+	collections := s.Collections(r.Context(), currentUser(r))
+
+	log.Info("Получение коллекций прошло успешно", "user_id", currentUser(r), "found", len(collections))
+
+	return collections
+}
+
+func CreateCollection(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.Collection, error) {
+	defer r.Body.Close()
+
+	name, err := collectionName(r)
+	if err != nil {
+		return storage.Collection{}, err
+	}
+
+	collection, err := s.CreateCollection(r.Context(), currentUser(r), name)
+	if err != nil {
+		return storage.Collection{}, fmt.Errorf("Ошибка при создании коллекции: %w", err)
+	}
+
+	log.Info("Создана коллекция", "user_id", currentUser(r), "collection_id", collection.ID, "name", collection.Name)
+
+	return collection, nil
+}
+
+func GetCollection(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.CollectionQuotes, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["cid"])
+	if err != nil {
+		return storage.CollectionQuotes{}, fmt.Errorf("Неверный формат ID коллекции: %v", err)
+	}
+
+	collection, err := s.GetCollection(r.Context(), currentUser(r), id)
+	if err != nil {
+		return storage.CollectionQuotes{}, fmt.Errorf("Ошибка при получении коллекции: %w", err)
+	}
+
+	log.Info("Получение коллекции прошло успешно", "user_id", currentUser(r), "collection_id", id)
+
+	return collection, nil
+}
+
+func RenameCollection(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.Collection, error) {
+	defer r.Body.Close()
+
+	id, err := strconv.Atoi(mux.Vars(r)["cid"])
+	if err != nil {
+		return storage.Collection{}, fmt.Errorf("Неверный формат ID коллекции: %v", err)
+	}
+
+	name, err := collectionName(r)
+	if err != nil {
+		return storage.Collection{}, err
+	}
+
+	collection, err := s.RenameCollection(r.Context(), currentUser(r), id, name)
+	if err != nil {
+		return storage.Collection{}, fmt.Errorf("Ошибка при переименовании коллекции: %w", err)
+	}
+
+	log.Info("Коллекция переименована", "user_id", currentUser(r), "collection_id", id, "name", name)
+
+	return collection, nil
+}
+
+func DeleteCollection(s *storage.JSONStorage, log *logger.Logger, r *http.Request) error {
+	id, err := strconv.Atoi(mux.Vars(r)["cid"])
+	if err != nil {
+		return fmt.Errorf("Неверный формат ID коллекции: %v", err)
+	}
+
+	if err = s.DeleteCollection(r.Context(), currentUser(r), id); err != nil {
+		return fmt.Errorf("Ошибка при удалении коллекции: %w", err)
+	}
+
+	log.Info("Коллекция удалена", "user_id", currentUser(r), "collection_id", id)
+
+	return nil
+}
+
+func AddToCollection(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.Collection, error) {
+	id, quoteID, err := collectionQuoteIDs(r)
+	if err != nil {
+		return storage.Collection{}, err
+	}
+
+	collection, err := s.AddToCollection(r.Context(), currentUser(r), id, quoteID)
+	if err != nil {
+		return storage.Collection{}, fmt.Errorf("Ошибка при добавлении цитаты в коллекцию: %w", err)
+	}
+
+	log.Info("Цитата добавлена в коллекцию", "user_id", currentUser(r), "collection_id", id, "id", quoteID)
+
+	return collection, nil
+}
+
+func RemoveFromCollection(s *storage.JSONStorage, log *logger.Logger, r *http.Request) (storage.Collection, error) {
+	id, quoteID, err := collectionQuoteIDs(r)
+	if err != nil {
+		return storage.Collection{}, err
+	}
+
+	collection, err := s.RemoveFromCollection(r.Context(), currentUser(r), id, quoteID)
+	if err != nil {
+		return storage.Collection{}, fmt.Errorf("Ошибка при удалении цитаты из коллекции: %w", err)
+	}
+
+	log.Info("Цитата удалена из коллекции", "user_id", currentUser(r), "collection_id", id, "id", quoteID)
+
+	return collection, nil
+}
+
+// collectionName читает из тела запроса непустое имя коллекции.
+func collectionName(r *http.Request) (string, error) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := decodeJSON(r, &request); err != nil {
+		return "", fmt.Errorf("Не удалось декодировать JSON из запроса: %w", err)
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxCollectionLength {
+		return "", fmt.Errorf("Длина имени коллекции должна быть от 1 до %d символов", maxCollectionLength)
+	}
+	return name, nil
+}
+
+func collectionQuoteIDs(r *http.Request) (int, int, error) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["cid"])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Неверный формат ID коллекции: %v", err)
+	}
+	quoteID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Неверный формат ID: %v", err)
+	}
+	return id, quoteID, nil
+}
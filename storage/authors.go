@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+type AuthorSuggestion struct {
+	Author string `json:"author"`
+	Count  int    `json:"count"`
+}
+
+type authorEntry struct {
+	name  string
+	count int
+}
+
+// authorIndex хранит авторов, отсортированных по нормализованному имени,
+// чтобы поиск по префиксу сводился к бинарному поиску.
+type authorIndex struct {
+	entries map[string]*authorEntry
+	keys    []string
+}
+
+func newAuthorIndex(quotes []QuoteStore) *authorIndex {
+	index := &authorIndex{entries: make(map[string]*authorEntry)}
+	for _, quote := range quotes {
+		if quote.Visible() {
+			index.add(quote.Author)
+		}
+	}
+	return index
+}
+
+// NormalizeAuthor приводит имя к нижнему регистру, убирает диакритику
+// и лишние пробелы.
+func NormalizeAuthor(name string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, name)
+	if err != nil {
+		result = name
+	}
+	return strings.Join(strings.Fields(strings.ToLower(result)), " ")
+}
+
+func (index *authorIndex) add(name string) {
+	key := NormalizeAuthor(name)
+	if key == "" {
+		return
+	}
+
+	if entry, ok := index.entries[key]; ok {
+		entry.count++
+		return
+	}
+
+	index.entries[key] = &authorEntry{name: strings.TrimSpace(name), count: 1}
+
+	i := sort.SearchStrings(index.keys, key)
+	index.keys = append(index.keys, "")
+	copy(index.keys[i+1:], index.keys[i:])
+	index.keys[i] = key
+}
+
+func (index *authorIndex) remove(name string) {
+	key := NormalizeAuthor(name)
+	entry, ok := index.entries[key]
+	if !ok {
+		return
+	}
+
+	entry.count--
+	if entry.count > 0 {
+		return
+	}
+
+	delete(index.entries, key)
+	i := sort.SearchStrings(index.keys, key)
+	if i < len(index.keys) && index.keys[i] == key {
+		index.keys = append(index.keys[:i], index.keys[i+1:]...)
+	}
+}
+
+func (index *authorIndex) suggest(prefix string, limit int) []AuthorSuggestion {
+	prefix = NormalizeAuthor(prefix)
+
+	suggestions := []AuthorSuggestion{}
+	for i := sort.SearchStrings(index.keys, prefix); i < len(index.keys); i++ {
+		if !strings.HasPrefix(index.keys[i], prefix) {
+			break
+		}
+		entry := index.entries[index.keys[i]]
+		suggestions = append(suggestions, AuthorSuggestion{Author: entry.name, Count: entry.count})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Count > suggestions[j].Count
+	})
+
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	return suggestions
+}
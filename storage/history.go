@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionRevert    = "revert"
+	ActionRestore   = "restore"
+	ActionPurge     = "purge"
+	ActionSubmit    = "submit"
+	ActionApprove   = "approve"
+	ActionReject    = "reject"
+	ActionHide      = "hide"
+	ActionUnhide    = "unhide"
+	ActionTranslate = "translate"
+)
+
+var ErrRevisionNotFound = errors.New("Ревизия не найдена")
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Revision неизменяемая запись об изменении цитаты. Snapshot хранит
+// состояние цитаты после изменения, а для окончательного удаления - до него.
+type Revision struct {
+	Rev      int           `json:"rev"`
+	QuoteID  int           `json:"quote_id"`
+	Action   string        `json:"action"`
+	Version  int           `json:"version"`
+	Actor    string        `json:"actor"`
+	Time     time.Time     `json:"time"`
+	Diff     []FieldChange `json:"diff"`
+	Snapshot Quote         `json:"snapshot"`
+	RevertOf int           `json:"revert_of,omitempty"`
+	// State служебное состояние цитаты, записывается только при
+	// окончательном удалении, см. QuoteState.
+	State *QuoteState `json:"state,omitempty"`
+}
+
+// QuoteState поля цитаты, которых нет в Snapshot, но которые нужны Revert,
+// чтобы создать окончательно удаленную цитату заново такой, какой она была:
+// с владельцем, статусом модерации, скрытием и переводами.
+type QuoteState struct {
+	SubmittedBy  string                 `json:"submitted_by,omitempty"`
+	Owner        string                 `json:"owner,omitempty"`
+	Status       string                 `json:"status,omitempty"`
+	Moderation   *Moderation            `json:"moderation,omitempty"`
+	Flags        []string               `json:"flags,omitempty"`
+	Hidden       bool                   `json:"hidden,omitempty"`
+	Translations map[string]Translation `json:"translations,omitempty"`
+}
+
+// historyPath возвращает путь к файлу истории рядом с файлом цитат:
+// ./storage/quotes.json -> ./storage/quotes.history.json.
+func historyPath(filename string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + ".history" + ext
+}
+
+func diffQuotes(before, after QuoteStore) []FieldChange {
+	changes := []FieldChange{}
+
+	if before.Quote != after.Quote {
+		changes = append(changes, FieldChange{Field: "quote", Old: before.Quote, New: after.Quote})
+	}
+	if before.Author != after.Author {
+		changes = append(changes, FieldChange{Field: "author", Old: before.Author, New: after.Author})
+	}
+	oldTags, newTags := strings.Join(before.Tags, ", "), strings.Join(after.Tags, ", ")
+	if oldTags != newTags {
+		changes = append(changes, FieldChange{Field: "tags", Old: oldTags, New: newTags})
+	}
+	if before.Language != after.Language {
+		changes = append(changes, FieldChange{Field: "language", Old: before.Language, New: after.Language})
+	}
+	for _, lang := range translationLanguages(before, after) {
+		oldText, newText := before.Translations[lang].text(), after.Translations[lang].text()
+		if oldText != newText {
+			changes = append(changes, FieldChange{Field: "translation:" + lang, Old: oldText, New: newText})
+		}
+	}
+	if before.Status != after.Status && after.ID != 0 {
+		changes = append(changes, FieldChange{Field: "status", Old: before.Status, New: after.Status})
+	}
+	if before.Hidden != after.Hidden && after.ID != 0 {
+		changes = append(changes, FieldChange{Field: "hidden", Old: strconv.FormatBool(before.Hidden), New: strconv.FormatBool(after.Hidden)})
+	}
+
+	return changes
+}
+
+// record добавляет ревизию в историю цитаты. Вызывается под storage.mute.
+func (storage *JSONStorage) record(action string, before, after QuoteStore, actor string) Revision {
+	return storage.commit(storage.newRevision(action, before, after, actor), before, after)
+}
+
+// newRevision вызывается под storage.mute.
+func (storage *JSONStorage) newRevision(action string, before, after QuoteStore, actor string) Revision {
+	id, snapshot := after.ID, after
+	if after.ID == 0 {
+		id, snapshot = before.ID, before
+	}
+
+	var state *QuoteState
+	if after.ID == 0 {
+		state = &QuoteState{
+			SubmittedBy:  before.SubmittedBy,
+			Owner:        before.Owner,
+			Status:       before.Status,
+			Moderation:   before.Moderation,
+			Flags:        before.Flags,
+			Hidden:       before.Hidden,
+			Translations: before.Translations,
+		}
+	}
+
+	return Revision{
+		Rev:     len(storage.History[id]) + 1,
+		QuoteID: id,
+		Action:  action,
+		Version: snapshot.Version,
+		Actor:   actor,
+		Time:    time.Now(),
+		Diff:    diffQuotes(before, after),
+		Snapshot: Quote{
+			Quote:    snapshot.Quote,
+			Author:   snapshot.Author,
+			Tags:     snapshot.Tags,
+			Language: snapshot.Language,
+		},
+		State: state,
+	}
+}
+
+// commit сохраняет ревизию и вызывает хуки. Вызывается под storage.mute.
+func (storage *JSONStorage) commit(revision Revision, before, after QuoteStore) Revision {
+	if storage.History == nil {
+		storage.History = make(map[int][]Revision)
+	}
+	storage.History[revision.QuoteID] = append(storage.History[revision.QuoteID], revision)
+	storage.markDirty()
+
+	for _, hook := range storage.hooks {
+		hook(revision, before, after)
+	}
+
+	return revision
+}
+
+// GetHistory возвращает ревизии цитаты. История цитат, скрытых от читающих
+// методов (на модерации, отклоненных, скрытых по жалобам, в корзине и
+// окончательно удаленных), отдается только при hidden, иначе такая цитата
+// считается не найденной.
+func (storage *JSONStorage) GetHistory(ctx context.Context, id int, hidden bool) ([]Revision, error) {
+	defer storage.begin(ctx, "get_history")()
+
+	if !hidden && storage.findVisible(id) < 0 {
+		return nil, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, id)
+	}
+
+	// Цитаты из файлов, созданных до появления истории, ревизий не имеют.
+	revisions, ok := storage.History[id]
+	if !ok && storage.find(id) < 0 {
+		return nil, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, id)
+	}
+
+	result := make([]Revision, len(revisions))
+	copy(result, revisions)
+
+	return result, nil
+}
+
+// Revert восстанавливает состояние цитаты из ревизии rev. Цитата из корзины
+// восстанавливается, а окончательно удаленная создается заново с прежним ID.
+func (storage *JSONStorage) Revert(ctx context.Context, id, rev int, actor string) (QuoteStore, error) {
+	defer storage.begin(ctx, "revert")()
+
+	revisions := storage.History[id]
+	if rev < 1 || rev > len(revisions) {
+		return QuoteStore{}, fmt.Errorf("%w: ревизия %d цитаты %d", ErrRevisionNotFound, rev, id)
+	}
+	snapshot := revisions[rev-1].Snapshot
+
+	var before, after QuoteStore
+	if i := storage.find(id); i >= 0 {
+		before = storage.Quotes[i]
+		after = before
+		after.Quote, after.Author, after.Tags = snapshot.Quote, snapshot.Author, snapshot.Tags
+		after.Language = snapshot.Language
+		after.DeletedAt = nil
+		after = storage.replace(i, after)
+	} else {
+		after = QuoteStore{
+			Quote:     snapshot.Quote,
+			Author:    snapshot.Author,
+			Tags:      snapshot.Tags,
+			Language:  snapshot.Language,
+			ID:        id,
+			Version:   revisions[len(revisions)-1].Version + 1,
+			CreatedAt: time.Now(),
+		}
+		restoreState(&after, revisions)
+		storage.insert(after)
+	}
+
+	revision := storage.newRevision(ActionRevert, before, after, actor)
+	revision.RevertOf = rev
+	storage.commit(revision, before, after)
+
+	return after, nil
+}
+
+// restoreState переносит в заново создаваемую цитату ее служебное состояние
+// из ревизии окончательного удаления. В истории, записанной до появления
+// QuoteState, автор, статус модерации и скрытие восстанавливаются по
+// действиям ревизий, а владелец остается пустым.
+func restoreState(quote *QuoteStore, revisions []Revision) {
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if state := revisions[i].State; state != nil {
+			quote.SubmittedBy, quote.Owner = state.SubmittedBy, state.Owner
+			quote.Status, quote.Moderation = state.Status, state.Moderation
+			quote.Flags, quote.Hidden = state.Flags, state.Hidden
+			quote.Translations = state.Translations
+			return
+		}
+	}
+
+	if first := revisions[0]; first.Action == ActionCreate || first.Action == ActionSubmit {
+		quote.SubmittedBy = first.Actor
+	}
+	for _, revision := range revisions {
+		switch revision.Action {
+		case ActionSubmit:
+			quote.Status = StatusPending
+		case ActionApprove:
+			quote.Status = StatusApproved
+		case ActionReject:
+			quote.Status = StatusRejected
+		case ActionHide:
+			quote.Hidden = true
+		case ActionUnhide:
+			quote.Hidden = false
+		}
+	}
+}
+
+func (storage *JSONStorage) loadHistory(filename string) error {
+	data, err := os.ReadFile(historyPath(filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			storage.History = make(map[int][]Revision)
+			return nil
+		}
+		return fmt.Errorf("Не удалось прочитать историю изменений: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &storage.History); err != nil {
+		return fmt.Errorf("Не удалось десериализовать историю изменений: %w", err)
+	}
+	if storage.History == nil {
+		storage.History = make(map[int][]Revision)
+	}
+
+	return nil
+}
+
+// saveHistory вызывается под storage.mute.
+func (storage *JSONStorage) saveHistory(filename string) error {
+	path := historyPath(filename)
+
+	if len(storage.History) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(storage.History)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Submit добавляет цитату в очередь модерации. До одобрения она не видна
+// читающим методам. flags - правила фильтра содержимого, из-за которых
+// цитата попала на модерацию.
+func (storage *JSONStorage) Submit(ctx context.Context, quote Quote, actor string, flags ...string) QuoteStore {
+	defer storage.begin(ctx, "submit")()
+
+	quoteStore := QuoteStore{
+		Quote:       quote.Quote,
+		Author:      quote.Author,
+		Tags:        quote.Tags,
+		Language:    quote.Language,
+		ID:          storage.IdCounter,
+		Version:     1,
+		CreatedAt:   time.Now(),
+		SubmittedBy: actor,
+		Owner:       quote.Owner,
+		Status:      StatusPending,
+		Flags:       flags,
+	}
+
+	storage.insert(quoteStore)
+	storage.record(ActionSubmit, QuoteStore{}, quoteStore, actor)
+
+	storage.IdCounter++
+
+	return quoteStore
+}
+
+// SubmitUpdate изменяет цитату как UpdateQuoteID, но возвращает ее в очередь
+// модерации: до одобрения измененная цитата не видна читающим методам.
+// flags - правила фильтра содержимого, пометившие изменение.
+func (storage *JSONStorage) SubmitUpdate(ctx context.Context, id int, quote Quote, version int, actor string, flags ...string) (QuoteStore, error) {
+	defer storage.begin(ctx, "submit_update")()
+
+	i, err := storage.findVersion(id, version)
+	if err != nil {
+		return QuoteStore{}, err
+	}
+
+	return storage.resubmit(i, storage.Quotes[i].withText(quote), actor, flags), nil
+}
+
+// SubmitTranslation сохраняет перевод как SetTranslation, но возвращает
+// цитату в очередь модерации.
+func (storage *JSONStorage) SubmitTranslation(ctx context.Context, id int, lang string, translation Translation, version int, actor string, flags ...string) (QuoteStore, error) {
+	defer storage.begin(ctx, "submit_translation")()
+
+	i, after, err := storage.translate(id, lang, translation, version, actor)
+	if err != nil {
+		return QuoteStore{}, err
+	}
+
+	return storage.resubmit(i, after, actor, flags), nil
+}
+
+// resubmit заменяет цитату на позиции i измененной after и отправляет ее на
+// модерацию. Прежнее решение модератора сбрасывается. Вызывается под
+// storage.mute.
+func (storage *JSONStorage) resubmit(i int, after QuoteStore, actor string, flags []string) QuoteStore {
+	before := storage.Quotes[i]
+	after.Status = StatusPending
+	after.Moderation = nil
+	after.Flags = flags
+
+	after = storage.replace(i, after)
+	storage.record(ActionSubmit, before, after, actor)
+
+	return after
+}
+
+// ModerationQueue возвращает ожидающие модерации цитаты, старые первыми.
+func (storage *JSONStorage) ModerationQueue(ctx context.Context) []QuoteStore {
+	defer storage.begin(ctx, "moderation_queue")()
+
+	queue := []QuoteStore{}
+	for _, quote := range storage.Quotes {
+		if quote.Status == StatusPending && quote.DeletedAt == nil {
+			queue = append(queue, quote)
+		}
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].CreatedAt.Before(queue[j].CreatedAt)
+	})
+
+	return queue
+}
+
+// Approve публикует цитату из очереди модерации.
+func (storage *JSONStorage) Approve(ctx context.Context, id int, actor string) (QuoteStore, error) {
+	defer storage.begin(ctx, "approve")()
+
+	return storage.moderate(id, StatusApproved, ActionApprove, actor, "")
+}
+
+// Reject отклоняет цитату из очереди модерации. Отклоненная цитата остается
+// скрытой вместе с причиной отказа.
+func (storage *JSONStorage) Reject(ctx context.Context, id int, actor, reason string) (QuoteStore, error) {
+	defer storage.begin(ctx, "reject")()
+
+	return storage.moderate(id, StatusRejected, ActionReject, actor, reason)
+}
+
+// moderate вызывается под storage.mute.
+func (storage *JSONStorage) moderate(id int, status, action, actor, reason string) (QuoteStore, error) {
+	i := storage.find(id)
+	if i < 0 || storage.Quotes[i].Status != StatusPending || storage.Quotes[i].DeletedAt != nil {
+		return QuoteStore{}, fmt.Errorf("%w в очереди модерации: ID %d", ErrQuoteNotFound, id)
+	}
+
+	before := storage.Quotes[i]
+	after := before
+	after.Status = status
+	after.Moderation = &Moderation{Actor: actor, Time: time.Now(), Reason: reason}
+
+	after = storage.replace(i, after)
+	storage.record(action, before, after, actor)
+
+	return after, nil
+}
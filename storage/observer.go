@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"quotes/tracing"
+	"time"
+)
+
+// Observer получает длительность операций хранилища, например для метрик.
+// Методы вызываются под блокировкой хранилища и не должны обращаться к нему.
+type Observer interface {
+	ObserveOperation(operation string, duration time.Duration)
+	ObserveSave(duration time.Duration, err error)
+}
+
+func (storage *JSONStorage) SetObserver(observer Observer) {
+	storage.mute.Lock()
+	defer storage.mute.Unlock()
+
+	storage.observer = observer
+}
+
+// begin начинает span операции и захватывает блокировку хранилища; ожидание
+// блокировки выделено в отдельный span. Возвращенную функцию нужно вызвать
+// через defer: она сообщает длительность наблюдателю, снимает блокировку и
+// завершает span.
+func (storage *JSONStorage) begin(ctx context.Context, operation string) func() {
+	ctx, span := tracing.Start(ctx, "storage."+operation)
+	storage.lock(ctx)
+
+	start := time.Now()
+	return func() {
+		storage.observe(operation, start)
+		storage.mute.Unlock()
+		span.End()
+	}
+}
+
+// lock захватывает блокировку хранилища, выделяя ожидание в отдельный span.
+func (storage *JSONStorage) lock(ctx context.Context) {
+	_, span := tracing.Start(ctx, "storage.lock")
+	storage.mute.Lock()
+	span.End()
+}
+
+// observe сообщает наблюдателю длительность операции, начатой в start.
+// Вызывается через defer под storage.mute.
+func (storage *JSONStorage) observe(operation string, start time.Time) {
+	if storage.observer != nil {
+		storage.observer.ObserveOperation(operation, time.Since(start))
+	}
+}
+
+// Counts возвращает количество видимых цитат и различных авторов.
+func (storage *JSONStorage) Counts() (quotes, authors int) {
+	storage.mute.Lock()
+	defer storage.mute.Unlock()
+
+	return storage.counters().total, len(storage.index().keys)
+}
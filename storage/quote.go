@@ -1,12 +1,78 @@
 package storage
 
+import "time"
+
+// Статусы модерации цитаты. Пустой статус у цитат, добавленных без
+// модерации, равнозначен StatusApproved.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Moderation решение модератора по цитате.
+type Moderation struct {
+	Actor  string    `json:"actor"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason,omitempty"`
+}
+
 type Quote struct {
-	Quote  string `json:"quote"`
-	Author string `json:"author"`
+	Quote  string   `json:"quote"`
+	Author string   `json:"author"`
+	Tags   []string `json:"tags,omitempty"`
+	// Language язык оригинала, код BCP 47: "ru", "en", "pt-BR".
+	Language string `json:"language,omitempty"`
+	// Owner владелец добавляемой цитаты, см. QuoteStore.Owner. Задается
+	// сервером и не читается из запроса.
+	Owner string `json:"-"`
+}
+
+// Translation перевод цитаты на другой язык. Author - имя автора в этом
+// языке, если оно пишется иначе.
+type Translation struct {
+	Quote     string    `json:"quote"`
+	Author    string    `json:"author,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
 }
 
 type QuoteStore struct {
-	Quote  string `json:"quote"`
-	Author string `json:"author"`
-	ID     int    `json:"id"`
+	Quote     string     `json:"quote"`
+	Author    string     `json:"author"`
+	Tags      []string   `json:"tags,omitempty"`
+	Language  string     `json:"language,omitempty"`
+	ID        int        `json:"id"`
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// SubmittedBy имя клиента, добавившего цитату.
+	SubmittedBy string `json:"submitted_by,omitempty"`
+	// Owner идентификатор клиента, добавившего цитату (auth.Principal.ClientID),
+	// используется для проверки прав на изменение своих цитат.
+	Owner string `json:"owner,omitempty"`
+	// Status статус модерации, см. StatusPending.
+	Status     string      `json:"status,omitempty"`
+	Moderation *Moderation `json:"moderation,omitempty"`
+	// Flags правила фильтра содержимого, отправившие цитату на модерацию.
+	Flags []string `json:"flags,omitempty"`
+	// Hidden цитата скрыта из-за жалоб до решения администратора, см. Report.
+	Hidden bool `json:"hidden,omitempty"`
+	// Translations переводы цитаты по кодам языков, см. SetTranslation.
+	Translations map[string]Translation `json:"translations,omitempty"`
+	// Translated язык перевода, который показан в Quote и Author вместо
+	// оригинала. Заполняется только в ответах, в хранилище всегда пустой.
+	Translated string `json:"translated,omitempty"`
+	// Likes, RatingCount и RatingAverage пересчитываются по голосам
+	// клиентов, см. Like и Rate.
+	Likes         int     `json:"likes"`
+	RatingCount   int     `json:"rating_count"`
+	RatingAverage float64 `json:"rating_average"`
+}
+
+// Visible сообщает, должна ли цитата отдаваться читающими методами: она не
+// в корзине, не скрыта из-за жалоб и одобрена модератором или добавлена без
+// модерации.
+func (quote QuoteStore) Visible() bool {
+	return quote.DeletedAt == nil && !quote.Hidden && (quote.Status == "" || quote.Status == StatusApproved)
 }
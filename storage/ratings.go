@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MinStars = 1
+	MaxStars = 5
+	// ratingPriorWeight сколько воображаемых средних оценок добавляется к
+	// оценкам цитаты при байесовском усреднении. Чем больше, тем больше
+	// настоящих оценок нужно цитате, чтобы подняться выше среднего.
+	ratingPriorWeight = 5
+)
+
+var ErrInvalidRating = errors.New("Оценка должна быть от 1 до 5")
+
+// votes лайки и оценки одной цитаты по идентификаторам клиентов. Каждый
+// клиент ставит не больше одного лайка и одной оценки.
+type votes struct {
+	Likes   map[string]time.Time `json:"likes,omitempty"`
+	Ratings map[string]int       `json:"ratings,omitempty"`
+}
+
+// RatedQuote цитата с байесовской оценкой.
+type RatedQuote struct {
+	QuoteStore
+	Score float64 `json:"score"`
+}
+
+// votesPath возвращает путь к файлу голосов рядом с файлом цитат:
+// ./storage/quotes.json -> ./storage/quotes.votes.json.
+func votesPath(filename string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + ".votes" + ext
+}
+
+// Like отмечает, что цитата понравилась клиенту voter. Повторный лайк того же
+// клиента ничего не меняет. Лайки и оценки не меняют версию цитаты и не
+// попадают в историю изменений.
+func (storage *JSONStorage) Like(ctx context.Context, id int, voter string) (QuoteStore, error) {
+	defer storage.begin(ctx, "like")()
+
+	i := storage.findVisible(id)
+	if i < 0 {
+		return QuoteStore{}, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, id)
+	}
+
+	v := storage.votesOf(id)
+	if v.Likes == nil {
+		v.Likes = make(map[string]time.Time)
+	}
+	if _, ok := v.Likes[voter]; !ok {
+		v.Likes[voter] = time.Now()
+		storage.markDirty()
+	}
+
+	return storage.tally(i), nil
+}
+
+func (storage *JSONStorage) Unlike(ctx context.Context, id int, voter string) (QuoteStore, error) {
+	defer storage.begin(ctx, "unlike")()
+
+	i := storage.findVisible(id)
+	if i < 0 {
+		return QuoteStore{}, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, id)
+	}
+
+	if v, ok := storage.votes[id]; ok {
+		if _, liked := v.Likes[voter]; liked {
+			delete(v.Likes, voter)
+			storage.markDirty()
+		}
+	}
+
+	return storage.tally(i), nil
+}
+
+// Rate ставит цитате оценку от MinStars до MaxStars. Повторная оценка того же
+// клиента заменяет предыдущую.
+func (storage *JSONStorage) Rate(ctx context.Context, id int, voter string, stars int) (QuoteStore, error) {
+	defer storage.begin(ctx, "rate")()
+
+	if stars < MinStars || stars > MaxStars {
+		return QuoteStore{}, fmt.Errorf("%w: %d", ErrInvalidRating, stars)
+	}
+
+	i := storage.findVisible(id)
+	if i < 0 {
+		return QuoteStore{}, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, id)
+	}
+
+	v := storage.votesOf(id)
+	if v.Ratings == nil {
+		v.Ratings = make(map[string]int)
+	}
+	if v.Ratings[voter] != stars {
+		v.Ratings[voter] = stars
+		storage.markDirty()
+	}
+
+	return storage.tally(i), nil
+}
+
+// TopRated возвращает до limit оцененных цитат с наибольшей байесовской
+// оценкой: средняя оценка цитаты сглаживается к средней оценке всех цитат,
+// поэтому одна пятерка не поднимает цитату выше десятка четверок.
+func (storage *JSONStorage) TopRated(ctx context.Context, limit int) []RatedQuote {
+	defer storage.begin(ctx, "top_rated")()
+
+	var total float64
+	var count int
+	for _, quote := range storage.Quotes {
+		if quote.Visible() {
+			total += quote.RatingAverage * float64(quote.RatingCount)
+			count += quote.RatingCount
+		}
+	}
+
+	rated := []RatedQuote{}
+	if count == 0 {
+		return rated
+	}
+	mean := total / float64(count)
+
+	for _, quote := range storage.Quotes {
+		if !quote.Visible() || quote.RatingCount == 0 {
+			continue
+		}
+		n := float64(quote.RatingCount)
+		rated = append(rated, RatedQuote{
+			QuoteStore: quote,
+			Score:      (ratingPriorWeight*mean + quote.RatingAverage*n) / (ratingPriorWeight + n),
+		})
+	}
+
+	sort.SliceStable(rated, func(i, j int) bool {
+		if rated[i].Score != rated[j].Score {
+			return rated[i].Score > rated[j].Score
+		}
+		return rated[i].ID < rated[j].ID
+	})
+	if len(rated) > limit {
+		rated = rated[:limit]
+	}
+
+	return rated
+}
+
+// votesOf возвращает голоса цитаты, создавая их при необходимости.
+// Вызывается под storage.mute.
+func (storage *JSONStorage) votesOf(id int) *votes {
+	if storage.votes == nil {
+		storage.votes = make(map[int]*votes)
+	}
+	v, ok := storage.votes[id]
+	if !ok {
+		v = &votes{}
+		storage.votes[id] = v
+	}
+	return v
+}
+
+// tally пересчитывает лайки и среднюю оценку цитаты на позиции i по ее
+// голосам. Вызывается под storage.mute.
+func (storage *JSONStorage) tally(i int) QuoteStore {
+	quote := &storage.Quotes[i]
+	quote.Likes, quote.RatingCount, quote.RatingAverage = 0, 0, 0
+
+	v, ok := storage.votes[quote.ID]
+	if !ok {
+		return *quote
+	}
+
+	quote.Likes = len(v.Likes)
+	quote.RatingCount = len(v.Ratings)
+	if quote.RatingCount > 0 {
+		sum := 0
+		for _, stars := range v.Ratings {
+			sum += stars
+		}
+		quote.RatingAverage = float64(sum) / float64(quote.RatingCount)
+	}
+
+	return *quote
+}
+
+// loadVotes читает голоса и пересчитывает по ним счетчики цитат.
+func (storage *JSONStorage) loadVotes(filename string) error {
+	data, err := os.ReadFile(votesPath(filename))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Не удалось прочитать голоса: %w", err)
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, &storage.votes); err != nil {
+			return fmt.Errorf("Не удалось десериализовать голоса: %w", err)
+		}
+	}
+
+	for i := range storage.Quotes {
+		storage.tally(i)
+	}
+
+	return nil
+}
+
+// saveVotes вызывается под storage.mute.
+func (storage *JSONStorage) saveVotes(filename string) error {
+	path := votesPath(filename)
+
+	if len(storage.votes) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(storage.votes)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Категории жалоб на цитату.
+const (
+	ReportMisattributed = "misattributed"
+	ReportOffensive     = "offensive"
+	ReportSpam          = "spam"
+	ReportDuplicate     = "duplicate"
+	ReportOther         = "other"
+)
+
+// Решения по жалобам, см. ResolveReports.
+const (
+	ResolutionDismiss = "dismiss"
+	ResolutionRemove  = "remove"
+)
+
+var ReportCategories = []string{ReportMisattributed, ReportOffensive, ReportSpam, ReportDuplicate, ReportOther}
+
+var (
+	ErrInvalidReport   = errors.New("Неизвестная категория жалобы")
+	ErrDuplicateReport = errors.New("Жалоба на цитату уже отправлена")
+	ErrReportNotFound  = errors.New("Открытые жалобы не найдены")
+)
+
+// Report жалоба клиента на цитату. Жалоба открыта, пока ResolvedAt пустое.
+// Anonymous отмечает жалобу клиента без учетных данных.
+type Report struct {
+	Reporter   string     `json:"reporter"`
+	Anonymous  bool       `json:"anonymous,omitempty"`
+	Category   string     `json:"category"`
+	Comment    string     `json:"comment,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy string     `json:"resolved_by,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+	Note       string     `json:"note,omitempty"`
+}
+
+// ReportSummary жалобы на одну цитату: число открытых жалоб по категориям и
+// сами жалобы, новые первыми.
+type ReportSummary struct {
+	QuoteID    int            `json:"quote_id"`
+	Quote      QuoteStore     `json:"quote"`
+	Open       int            `json:"open"`
+	Categories map[string]int `json:"categories"`
+	Reports    []Report       `json:"reports"`
+}
+
+// reportsPath возвращает путь к файлу жалоб рядом с файлом цитат:
+// ./storage/quotes.json -> ./storage/quotes.reports.json.
+func reportsPath(filename string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + ".reports" + ext
+}
+
+func validReportCategory(category string) bool {
+	for _, c := range ReportCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Report сохраняет жалобу клиента reporter на цитату. У клиента может быть
+// только одна открытая жалоба на цитату. Если открытых жалоб клиентов с
+// учетными данными набралось не меньше threshold, цитата скрывается до
+// решения администратора; нулевой threshold отключает скрытие. Жалобы
+// anonymous клиентов видны администраторам, но цитату не скрывают: адрес
+// клиента легко сменить.
+func (storage *JSONStorage) Report(ctx context.Context, id int, reporter string, anonymous bool, category, comment string, threshold int) (ReportSummary, error) {
+	defer storage.begin(ctx, "report")()
+
+	if !validReportCategory(category) {
+		return ReportSummary{}, fmt.Errorf("%w: %q", ErrInvalidReport, category)
+	}
+
+	i := storage.findVisible(id)
+	if i < 0 {
+		return ReportSummary{}, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, id)
+	}
+
+	for _, report := range storage.reports[id] {
+		if report.Reporter == reporter && report.ResolvedAt == nil {
+			return ReportSummary{}, fmt.Errorf("%w: ID %d", ErrDuplicateReport, id)
+		}
+	}
+
+	if storage.reports == nil {
+		storage.reports = make(map[int][]Report)
+	}
+	storage.reports[id] = append(storage.reports[id], Report{
+		Reporter:  reporter,
+		Anonymous: anonymous,
+		Category:  category,
+		Comment:   comment,
+		CreatedAt: time.Now(),
+	})
+	storage.markDirty()
+
+	summary := storage.summarize(i)
+	if threshold > 0 && storage.countedReports(id) >= threshold {
+		before := storage.Quotes[i]
+		after := before
+		after.Hidden = true
+
+		after = storage.replace(i, after)
+		storage.record(ActionHide, before, after, "system")
+		summary.Quote = after
+	}
+
+	return summary, nil
+}
+
+// Reports возвращает жалобы по цитатам: status "open" - только цитаты с
+// открытыми жалобами, "resolved" - только с закрытыми, пустой или "all" - все.
+// Цитаты с наибольшим числом открытых жалоб идут первыми.
+func (storage *JSONStorage) Reports(ctx context.Context, status string) []ReportSummary {
+	defer storage.begin(ctx, "reports")()
+
+	summaries := []ReportSummary{}
+	for i, quote := range storage.Quotes {
+		if _, ok := storage.reports[quote.ID]; !ok {
+			continue
+		}
+		summary := storage.summarize(i)
+		switch {
+		case status == "open" && summary.Open == 0:
+			continue
+		case status == "resolved" && summary.Open == len(summary.Reports):
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if summaries[i].Open != summaries[j].Open {
+			return summaries[i].Open > summaries[j].Open
+		}
+		return summaries[i].QuoteID < summaries[j].QuoteID
+	})
+
+	return summaries
+}
+
+// ResolveReports закрывает открытые жалобы на цитату. ResolutionDismiss
+// отклоняет жалобы и снова показывает скрытую цитату, ResolutionRemove
+// переносит цитату в корзину.
+func (storage *JSONStorage) ResolveReports(ctx context.Context, id int, resolution, actor, note string) (ReportSummary, error) {
+	defer storage.begin(ctx, "resolve_reports")()
+
+	if resolution != ResolutionDismiss && resolution != ResolutionRemove {
+		return ReportSummary{}, fmt.Errorf("Неизвестное решение по жалобам: %q", resolution)
+	}
+
+	i := storage.find(id)
+	if i < 0 || storage.Quotes[i].DeletedAt != nil {
+		return ReportSummary{}, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, id)
+	}
+
+	now := time.Now()
+	resolved := 0
+	for j := range storage.reports[id] {
+		report := &storage.reports[id][j]
+		if report.ResolvedAt != nil {
+			continue
+		}
+		report.ResolvedAt = &now
+		report.ResolvedBy = actor
+		report.Resolution = resolution
+		report.Note = note
+		resolved++
+	}
+	// Скрытую цитату можно вернуть и без открытых жалоб: они удаляются
+	// вместе с цитатой, а Revert восстанавливает ее скрытой.
+	if resolved == 0 && !(resolution == ResolutionDismiss && storage.Quotes[i].Hidden) {
+		return ReportSummary{}, fmt.Errorf("%w: ID %d", ErrReportNotFound, id)
+	}
+	storage.markDirty()
+
+	before := storage.Quotes[i]
+	after := before
+	after.Hidden = false
+	switch {
+	case resolution == ResolutionRemove:
+		after.DeletedAt = &now
+		after = storage.replace(i, after)
+		delete(storage.counters().served, id)
+		storage.record(ActionDelete, before, after, actor)
+	case before.Hidden:
+		after = storage.replace(i, after)
+		storage.record(ActionUnhide, before, after, actor)
+	}
+
+	return storage.summarize(i), nil
+}
+
+// countedReports возвращает число открытых жалоб клиентов с учетными
+// данными на цитату id. Вызывается под storage.mute.
+func (storage *JSONStorage) countedReports(id int) int {
+	counted := 0
+	for _, report := range storage.reports[id] {
+		if report.ResolvedAt == nil && !report.Anonymous {
+			counted++
+		}
+	}
+	return counted
+}
+
+// summarize собирает жалобы на цитату на позиции i. Вызывается под storage.mute.
+func (storage *JSONStorage) summarize(i int) ReportSummary {
+	quote := storage.Quotes[i]
+	summary := ReportSummary{
+		QuoteID:    quote.ID,
+		Quote:      quote,
+		Categories: make(map[string]int),
+		Reports:    []Report{},
+	}
+
+	reports := storage.reports[quote.ID]
+	for j := len(reports) - 1; j >= 0; j-- {
+		report := reports[j]
+		if report.ResolvedAt == nil {
+			summary.Open++
+			summary.Categories[report.Category]++
+		}
+		summary.Reports = append(summary.Reports, report)
+	}
+
+	return summary
+}
+
+func (storage *JSONStorage) loadReports(filename string) error {
+	data, err := os.ReadFile(reportsPath(filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("Не удалось прочитать жалобы: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &storage.reports); err != nil {
+		return fmt.Errorf("Не удалось десериализовать жалобы: %w", err)
+	}
+
+	return nil
+}
+
+// saveReports вызывается под storage.mute.
+func (storage *JSONStorage) saveReports(filename string) error {
+	path := reportsPath(filename)
+
+	if len(storage.reports) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(storage.reports)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type ServedQuote struct {
+	ID     int    `json:"id"`
+	Quote  string `json:"quote"`
+	Author string `json:"author"`
+	Served int    `json:"served"`
+}
+
+type Stats struct {
+	TotalQuotes     int                `json:"total_quotes"`
+	DistinctAuthors int                `json:"distinct_authors"`
+	TopAuthors      []AuthorSuggestion `json:"top_authors"`
+	TopTags         []TagCount         `json:"top_tags"`
+	AverageLength   float64            `json:"average_length"`
+	AddedPerDay     map[string]int     `json:"added_per_day"`
+	AddedPerWeek    map[string]int     `json:"added_per_week"`
+	MostServed      []ServedQuote      `json:"most_served"`
+}
+
+// statsCounters обновляются при каждом изменении хранилища, поэтому
+// запрос статистики не требует полного прохода по цитатам.
+type statsCounters struct {
+	total        int
+	totalLength  int
+	tags         map[string]int
+	addedPerDay  map[string]int
+	addedPerWeek map[string]int
+	// served хранится только в памяти и не сохраняется на диск.
+	served map[int]*ServedQuote
+}
+
+func newStatsCounters(quotes []QuoteStore) *statsCounters {
+	counters := &statsCounters{
+		tags:         make(map[string]int),
+		addedPerDay:  make(map[string]int),
+		addedPerWeek: make(map[string]int),
+		served:       make(map[int]*ServedQuote),
+	}
+	for _, quote := range quotes {
+		if quote.Visible() {
+			counters.add(quote)
+		}
+	}
+	return counters
+}
+
+func weekKey(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+func (counters *statsCounters) add(quote QuoteStore) {
+	counters.total++
+	counters.totalLength += utf8.RuneCountInString(quote.Quote)
+
+	for _, tag := range quote.Tags {
+		if tag = normalizeTag(tag); tag != "" {
+			counters.tags[tag]++
+		}
+	}
+
+	if !quote.CreatedAt.IsZero() {
+		counters.addedPerDay[quote.CreatedAt.UTC().Format("2006-01-02")]++
+		counters.addedPerWeek[weekKey(quote.CreatedAt.UTC())]++
+	}
+}
+
+func (counters *statsCounters) remove(quote QuoteStore) {
+	counters.total--
+	counters.totalLength -= utf8.RuneCountInString(quote.Quote)
+
+	for _, tag := range quote.Tags {
+		if tag = normalizeTag(tag); tag != "" {
+			decrement(counters.tags, tag)
+		}
+	}
+
+	if !quote.CreatedAt.IsZero() {
+		decrement(counters.addedPerDay, quote.CreatedAt.UTC().Format("2006-01-02"))
+		decrement(counters.addedPerWeek, weekKey(quote.CreatedAt.UTC()))
+	}
+}
+
+func decrement(counts map[string]int, key string) {
+	counts[key]--
+	if counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	result := make(map[string]int, len(counts))
+	for key, value := range counts {
+		result[key] = value
+	}
+	return result
+}
+
+func (counters *statsCounters) topTags(limit int) []TagCount {
+	tags := make([]TagCount, 0, len(counters.tags))
+	for tag, count := range counters.tags {
+		tags = append(tags, TagCount{Tag: tag, Count: count})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags
+}
+
+func (counters *statsCounters) recordServed(quote QuoteStore) {
+	entry, ok := counters.served[quote.ID]
+	if !ok {
+		entry = &ServedQuote{ID: quote.ID}
+		counters.served[quote.ID] = entry
+	}
+	entry.Quote = quote.Quote
+	entry.Author = quote.Author
+	entry.Served++
+}
+
+func (counters *statsCounters) mostServed(limit int) []ServedQuote {
+	served := make([]ServedQuote, 0, len(counters.served))
+	for _, entry := range counters.served {
+		served = append(served, *entry)
+	}
+
+	sort.Slice(served, func(i, j int) bool {
+		if served[i].Served != served[j].Served {
+			return served[i].Served > served[j].Served
+		}
+		return served[i].ID < served[j].ID
+	})
+
+	if len(served) > limit {
+		served = served[:limit]
+	}
+	return served
+}
@@ -1,97 +1,234 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"quotes/logger"
+	"quotes/tracing"
 	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type JSONStorage struct {
 	Quotes    []QuoteStore
+	History   map[int][]Revision
 	IdCounter int
 	mute      sync.Mutex
+	authors   *authorIndex
+	stats     *statsCounters
+	hooks     []ChangeHook
+	accounts  accounts
+	votes     map[int]*votes
+	reports   map[int][]Report
+	observer  Observer
+	savedAt   time.Time
+	saveErr   error
+	// dirty есть изменения, не записанные на диск.
+	dirty bool
+}
+
+// ChangeHook вызывается после каждого изменения цитаты. Вызов происходит под
+// блокировкой хранилища, поэтому хуки видят изменения строго по порядку и
+// не должны обращаться к хранилищу.
+type ChangeHook func(revision Revision, before, after QuoteStore)
+
+func (storage *JSONStorage) OnChange(hook ChangeHook) {
+	storage.mute.Lock()
+	defer storage.mute.Unlock()
+
+	storage.hooks = append(storage.hooks, hook)
 }
 
+var (
+	ErrQuoteNotFound   = errors.New("Цитата не найдена")
+	ErrVersionMismatch = errors.New("Версия цитаты не совпадает")
+)
+
 func CreateJSONStorage(filename string, log *logger.Logger) (*JSONStorage, error) {
 	var storage JSONStorage
+	storage.Quotes = []QuoteStore{}
 
 	data, err := os.ReadFile(filename)
-	if err != nil {
-		if os.IsNotExist(err) {
-			_, err = os.Create(filename)
-			if err != nil {
-				return &storage, fmt.Errorf("Не удалось создать файл: %w", err)
-			}
-			log.Info("Файл хранилища отсутствовал и был успешно создан")
-			storage.Quotes = []QuoteStore{}
-			storage.IdCounter = 1
-			return &storage, nil
+	switch {
+	case err != nil && !os.IsNotExist(err):
+		return &storage, fmt.Errorf("Не удалось прочитать файл: %w", err)
+	case err != nil:
+		file, err := os.Create(filename)
+		if err != nil {
+			return &storage, fmt.Errorf("Не удалось создать файл: %w", err)
+		}
+		file.Close()
+		log.Info("Файл хранилища отсутствовал и был успешно создан", "path", filename)
+	case len(data) == 0:
+		log.Info("Файл пустой, инициализация пустого хранилища")
+	default:
+		log.Info("Файл хранилища успешно открыт", "path", filename)
+		if err = json.Unmarshal(data, &storage.Quotes); err != nil {
+			return &storage, fmt.Errorf("Не удалось десериализовать данные: %w", err)
 		}
-	} else {
-		log.Info("Файл хранилища успшено открыт")
 	}
 
-	if len(data) == 0 {
-		log.Info("Файл пустой, инициализация пустого хранилища")
-		storage.Quotes = []QuoteStore{}
-		storage.IdCounter = 1
-		return &storage, nil
+	if err = storage.loadHistory(filename); err != nil {
+		return &storage, err
+	}
+	if err = storage.loadAccounts(filename); err != nil {
+		return &storage, err
+	}
+	if err = storage.loadVotes(filename); err != nil {
+		return &storage, err
+	}
+	if err = storage.loadReports(filename); err != nil {
+		return &storage, err
 	}
 
-	if err = json.Unmarshal(data, &storage.Quotes); err != nil {
-		return &storage, fmt.Errorf("Не удалось десериализовать данные: %w", err)
+	for i := range storage.Quotes {
+		if storage.Quotes[i].Version == 0 {
+			storage.Quotes[i].Version = 1
+		}
 	}
 
-	storage.IdCounter = len(storage.Quotes) + 1
+	storage.IdCounter = storage.nextID()
 
-	log.Info("Инициализация хранилища прошла успешно")
+	log.Info("Инициализация хранилища прошла успешно", "quotes", len(storage.Quotes))
 	return &storage, nil
 }
 
-func (storage *JSONStorage) Save(filename string, log *logger.Logger) error {
-	storage.mute.Lock()
+// nextID возвращает ID, не занятый ни одной цитатой, в том числе
+// удаленной, но оставшейся в истории изменений.
+func (storage *JSONStorage) nextID() int {
+	maxID := 0
+	for _, quote := range storage.Quotes {
+		if quote.ID > maxID {
+			maxID = quote.ID
+		}
+	}
+	for id := range storage.History {
+		if id > maxID {
+			maxID = id
+		}
+	}
+	return maxID + 1
+}
+
+func (storage *JSONStorage) Save(ctx context.Context, filename string, log *logger.Logger) (err error) {
+	ctx, span := tracing.Start(ctx, "storage.save", attribute.String("path", filename))
+	defer span.End()
+
+	storage.lock(ctx)
 	defer storage.mute.Unlock()
+
+	defer func(start time.Time) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		if err == nil {
+			storage.savedAt = time.Now()
+			storage.dirty = false
+		}
+		storage.saveErr = err
+		if storage.observer != nil {
+			storage.observer.ObserveSave(time.Since(start), err)
+		}
+	}(time.Now())
+
+	_, marshal := tracing.Start(ctx, "storage.marshal")
 	data, err := json.Marshal(storage.Quotes)
+	marshal.End()
 	if err != nil {
 		return err
 	}
-	log.Info("Сериализация данных прошла успешно")
+	log.Debug("Сериализация данных прошла успешно", "bytes", len(data))
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	_, write := tracing.Start(ctx, "storage.write", attribute.Int("bytes", len(data)))
+	err = os.WriteFile(filename, data, 0644)
+	write.End()
+	if err != nil {
 		return err
 	}
-	log.Info("Сохранение данных прошло успешно")
+
+	if err := storage.saveHistory(filename); err != nil {
+		return err
+	}
+	if err := storage.saveAccounts(filename); err != nil {
+		return err
+	}
+	if err := storage.saveVotes(filename); err != nil {
+		return err
+	}
+	if err := storage.saveReports(filename); err != nil {
+		return err
+	}
+	log.Info("Сохранение данных прошло успешно", "path", filename)
 
 	return nil
 }
 
-func (storage *JSONStorage) Add(quote Quote) {
+// SaveChanges сохраняет хранилище, если с прошлого успешного сохранения в
+// нем что-то изменилось, и сообщает, было ли сохранение.
+func (storage *JSONStorage) SaveChanges(ctx context.Context, filename string, log *logger.Logger) (bool, error) {
+	storage.mute.Lock()
+	dirty := storage.dirty
+	storage.mute.Unlock()
+
+	if !dirty {
+		return false, nil
+	}
+	return true, storage.Save(ctx, filename, log)
+}
+
+// markDirty отмечает хранилище измененным, чтобы изменение записал следующий
+// вызов SaveChanges. Вызывается под storage.mute после успешного изменения.
+func (storage *JSONStorage) markDirty() {
+	storage.dirty = true
+}
+
+// LastSave возвращает время последнего успешного сохранения (нулевое, если
+// сохранений еще не было) и ошибку последней попытки сохранения.
+func (storage *JSONStorage) LastSave() (time.Time, error) {
 	storage.mute.Lock()
 	defer storage.mute.Unlock()
 
+	return storage.savedAt, storage.saveErr
+}
+
+func (storage *JSONStorage) Add(ctx context.Context, quote Quote, actor string) QuoteStore {
+	defer storage.begin(ctx, "add")()
+
 	quoteStore := QuoteStore{
-		Quote:  quote.Quote,
-		Author: quote.Author,
-		ID:     storage.IdCounter,
+		Quote:       quote.Quote,
+		Author:      quote.Author,
+		Tags:        quote.Tags,
+		Language:    quote.Language,
+		ID:          storage.IdCounter,
+		Version:     1,
+		CreatedAt:   time.Now(),
+		SubmittedBy: actor,
+		Owner:       quote.Owner,
 	}
 
-	storage.Quotes = append(storage.Quotes, quoteStore)
+	storage.insert(quoteStore)
+	storage.record(ActionCreate, QuoteStore{}, quoteStore, actor)
 
 	storage.IdCounter++
-}
 
-func (storage *JSONStorage) GetQuotes() ([]Quote, error) {
-	storage.mute.Lock()
-	defer storage.mute.Unlock()
+	return quoteStore
+}
 
-	Quotes := make([]Quote, len(storage.Quotes))
+func (storage *JSONStorage) GetQuotes(ctx context.Context) ([]QuoteStore, error) {
+	defer storage.begin(ctx, "get_quotes")()
 
-	for i, quoteStore := range storage.Quotes {
-		Quotes[i] = Quote{
-			Quote:  quoteStore.Quote,
-			Author: quoteStore.Author,
+	Quotes := make([]QuoteStore, 0, len(storage.Quotes))
+	for _, quote := range storage.Quotes {
+		if quote.Visible() {
+			Quotes = append(Quotes, quote)
 		}
 	}
 
@@ -102,16 +239,193 @@ func (storage *JSONStorage) GetQuotes() ([]Quote, error) {
 	return Quotes, nil
 }
 
-func (storage *JSONStorage) DeleteQuoteID(id int) error {
-	storage.mute.Lock()
-	defer storage.mute.Unlock()
+func (storage *JSONStorage) GetQuote(ctx context.Context, id int) (QuoteStore, error) {
+	defer storage.begin(ctx, "get_quote")()
+
+	i := storage.findVisible(id)
+	if i < 0 {
+		return QuoteStore{}, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, id)
+	}
+
+	return storage.Quotes[i], nil
+}
+
+func (storage *JSONStorage) GetRandom(ctx context.Context) (QuoteStore, error) {
+	defer storage.begin(ctx, "get_random")()
 
+	visible := make([]int, 0, len(storage.Quotes))
+	for i, quote := range storage.Quotes {
+		if quote.Visible() {
+			visible = append(visible, i)
+		}
+	}
+
+	if len(visible) == 0 {
+		return QuoteStore{}, fmt.Errorf("Отсутствуют цитаты")
+	}
+
+	quoteStore := storage.Quotes[visible[rand.Intn(len(visible))]]
+	storage.counters().recordServed(quoteStore)
+
+	return quoteStore, nil
+}
+
+// UpdateQuoteID заменяет текст, автора, теги и язык цитаты. Если version не равна
+// нулю, изменение применяется только к цитате с этой версией.
+func (storage *JSONStorage) UpdateQuoteID(ctx context.Context, id int, quote Quote, version int, actor string) (QuoteStore, error) {
+	defer storage.begin(ctx, "update")()
+
+	i, err := storage.findVersion(id, version)
+	if err != nil {
+		return QuoteStore{}, err
+	}
+
+	before := storage.Quotes[i]
+	after := storage.replace(i, before.withText(quote))
+	storage.record(ActionUpdate, before, after, actor)
+
+	return after, nil
+}
+
+// withText возвращает копию цитаты с текстом, автором, тегами и языком из quote.
+func (quoteStore QuoteStore) withText(quote Quote) QuoteStore {
+	quoteStore.Quote = quote.Quote
+	quoteStore.Author = quote.Author
+	quoteStore.Language = quote.Language
+	quoteStore.Tags = quote.Tags
+	return quoteStore
+}
+
+// DeleteQuoteID помечает цитату удаленной. Окончательно она удаляется
+// из корзины методом PurgeTrash. Версия проверяется как в UpdateQuoteID.
+func (storage *JSONStorage) DeleteQuoteID(ctx context.Context, id int, version int, actor string) error {
+	defer storage.begin(ctx, "delete")()
+
+	i, err := storage.findVersion(id, version)
+	if err != nil {
+		return err
+	}
+
+	before := storage.Quotes[i]
+	after := before
+	deletedAt := time.Now()
+	after.DeletedAt = &deletedAt
+
+	after = storage.replace(i, after)
+	delete(storage.counters().served, id)
+	storage.record(ActionDelete, before, after, actor)
+
+	return nil
+}
+
+// find возвращает позицию цитаты в срезе или -1. Вызывается под storage.mute.
+func (storage *JSONStorage) find(id int) int {
 	for i, quote := range storage.Quotes {
 		if quote.ID == id {
-			storage.Quotes = append(storage.Quotes[:i], storage.Quotes[i+1:]...)
-			return nil
+			return i
 		}
 	}
+	return -1
+}
+
+// findVisible как find, но пропускает скрытые цитаты. Вызывается под storage.mute.
+func (storage *JSONStorage) findVisible(id int) int {
+	i := storage.find(id)
+	if i < 0 || !storage.Quotes[i].Visible() {
+		return -1
+	}
+	return i
+}
+
+// findVersion как findVisible, но дополнительно сверяет версию, если она
+// не равна нулю. Вызывается под storage.mute.
+func (storage *JSONStorage) findVersion(id int, version int) (int, error) {
+	i := storage.findVisible(id)
+	if i < 0 {
+		return -1, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, id)
+	}
+	if version != 0 && storage.Quotes[i].Version != version {
+		return -1, fmt.Errorf("%w: ожидалась %d, текущая %d", ErrVersionMismatch, version, storage.Quotes[i].Version)
+	}
+	return i, nil
+}
+
+// insert добавляет цитату и обновляет индексы. Вызывается под storage.mute.
+func (storage *JSONStorage) insert(quote QuoteStore) {
+	if quote.Visible() {
+		storage.index().add(quote.Author)
+		storage.counters().add(quote)
+	}
+	storage.Quotes = append(storage.Quotes, quote)
+}
+
+// replace заменяет цитату на позиции i, увеличивает ее версию и обновляет
+// индексы. Вызывается под storage.mute.
+func (storage *JSONStorage) replace(i int, quote QuoteStore) QuoteStore {
+	quote.Version = storage.Quotes[i].Version + 1
+	if old := storage.Quotes[i]; old.Visible() {
+		storage.index().remove(old.Author)
+		storage.counters().remove(old)
+	}
+	if quote.Visible() {
+		storage.index().add(quote.Author)
+		storage.counters().add(quote)
+	}
+	storage.Quotes[i] = quote
+	return quote
+}
+
+// removeAt окончательно удаляет цитату из среза. Вызывается под storage.mute.
+func (storage *JSONStorage) removeAt(i int) {
+	if old := storage.Quotes[i]; old.Visible() {
+		storage.index().remove(old.Author)
+		storage.counters().remove(old)
+	}
+	delete(storage.votes, storage.Quotes[i].ID)
+	delete(storage.reports, storage.Quotes[i].ID)
+	storage.Quotes = append(storage.Quotes[:i], storage.Quotes[i+1:]...)
+}
+
+func (storage *JSONStorage) SuggestAuthors(ctx context.Context, prefix string, limit int) []AuthorSuggestion {
+	defer storage.begin(ctx, "suggest_authors")()
+
+	return storage.index().suggest(prefix, limit)
+}
+
+// index лениво строит индекс авторов. Вызывается под storage.mute.
+func (storage *JSONStorage) index() *authorIndex {
+	if storage.authors == nil {
+		storage.authors = newAuthorIndex(storage.Quotes)
+	}
+	return storage.authors
+}
+
+func (storage *JSONStorage) Stats(ctx context.Context, limit int) Stats {
+	defer storage.begin(ctx, "stats")()
 
-	return fmt.Errorf("Цитата с указанным ID %d не найдена", id)
+	index := storage.index()
+	counters := storage.counters()
+
+	stats := Stats{
+		TotalQuotes:     counters.total,
+		DistinctAuthors: len(index.keys),
+		TopAuthors:      index.suggest("", limit),
+		TopTags:         counters.topTags(limit),
+		AddedPerDay:     copyCounts(counters.addedPerDay),
+		AddedPerWeek:    copyCounts(counters.addedPerWeek),
+		MostServed:      counters.mostServed(limit),
+	}
+	if stats.TotalQuotes > 0 {
+		stats.AverageLength = float64(counters.totalLength) / float64(stats.TotalQuotes)
+	}
+
+	return stats
+}
+
+// counters лениво строит счетчики статистики. Вызывается под storage.mute.
+func (storage *JSONStorage) counters() *statsCounters {
+	if storage.stats == nil {
+		storage.stats = newStatsCounters(storage.Quotes)
+	}
+	return storage.stats
 }
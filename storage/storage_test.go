@@ -1,19 +1,25 @@
 package storage_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"quotes/logger"
 	"quotes/storage"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreateJSONStorage(t *testing.T) {
-	log, err := logger.NewLogger()
+	log, err := logger.New(logger.Options{Outputs: []string{filepath.Join(t.TempDir(), "log.log")}})
 	if err != nil {
 		t.Fatalf("Не удалось создать логгер: %v", err)
 	}
-	defer os.Remove("log.log")
 
 	// Тест 1: Файл не существует
 	tempFile, err := os.CreateTemp("", "test_JSON.json")
@@ -76,11 +82,10 @@ func TestCreateJSONStorage(t *testing.T) {
 }
 
 func TestSave(t *testing.T) {
-	log, err := logger.NewLogger()
+	log, err := logger.New(logger.Options{Outputs: []string{filepath.Join(t.TempDir(), "log.log")}})
 	if err != nil {
 		t.Fatalf("Не удалось создать логгер: %v", err)
 	}
-	defer os.Remove("log.log")
 
 	tempFile, err := os.CreateTemp("", "test_JSON.json")
 	if err != nil {
@@ -96,7 +101,7 @@ func TestSave(t *testing.T) {
 		IdCounter: 3,
 	}
 
-	err = s.Save(tempFile.Name(), log)
+	err = s.Save(context.Background(), tempFile.Name(), log)
 	if err != nil {
 		t.Fatalf("Save вернула ошибку: %v", err)
 	}
@@ -124,8 +129,644 @@ func TestSave(t *testing.T) {
 
 	// Тест 2: Ошибка записи в файл
 	invalidPath := "/invalid/path/test.json"
-	err = s.Save(invalidPath, log)
+	err = s.Save(context.Background(), invalidPath, log)
 	if err == nil {
 		t.Error("Ожидалась ошибка при записи в недоступный путь")
 	}
 }
+
+func TestSaveChanges(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	s, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	ctx := context.Background()
+
+	// Тест 1: Изменение записывается
+	quote := s.Add(ctx, storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "alice")
+	if saved, err := s.SaveChanges(ctx, path, log); !saved || err != nil {
+		t.Fatalf("Ожидалось сохранение изменений, получено: %v, %v", saved, err)
+	}
+
+	// Тест 2: Неудачные изменения и повторы не требуют сохранения
+	s.Register(ctx, "bob", "hash")
+	s.Like(ctx, quote.ID, "alice")
+	s.SaveChanges(ctx, path, log)
+
+	s.UpdateQuoteID(ctx, quote.ID, storage.Quote{Quote: "Quote 2", Author: "Author 1"}, quote.Version+1, "alice")
+	s.DeleteQuoteID(ctx, 99, 0, "alice")
+	s.Register(ctx, "BOB", "hash")
+	s.Like(ctx, quote.ID, "alice")
+	s.Unlike(ctx, quote.ID, "bob")
+	s.Rate(ctx, quote.ID, "alice", 0)
+	s.Report(ctx, quote.ID, "alice", false, "unknown", "", 0)
+	s.ResolveReports(ctx, quote.ID, storage.ResolutionDismiss, "admin", "")
+	if saved, err := s.SaveChanges(ctx, path, log); saved || err != nil {
+		t.Errorf("Сохранение без изменений не ожидалось, получено: %v, %v", saved, err)
+	}
+}
+
+func TestSuggestAuthors(t *testing.T) {
+	s := &storage.JSONStorage{IdCounter: 1}
+
+	quotes := []storage.Quote{
+		{Quote: "Quote 1", Author: "Лев Толстой"},
+		{Quote: "Quote 2", Author: "Лев Толстой"},
+		{Quote: "Quote 3", Author: "Лермонтов"},
+		{Quote: "Quote 4", Author: "Émile Zola"},
+		{Quote: "Quote 5", Author: "Пушкин"},
+	}
+	for _, quote := range quotes {
+		s.Add(context.Background(), quote, "")
+	}
+
+	// Тест 1: Поиск по префиксу без учета регистра, сортировка по количеству цитат
+	suggestions := s.SuggestAuthors(context.Background(), "ЛЕ", 10)
+	if len(suggestions) != 2 {
+		t.Fatalf("Ожидалось 2 автора, получено: %+v", suggestions)
+	}
+	if suggestions[0].Author != "Лев Толстой" || suggestions[0].Count != 2 {
+		t.Errorf("Ожидался 'Лев Толстой' с 2 цитатами первым, получено: %+v", suggestions[0])
+	}
+
+	// Тест 2: Поиск без учета диакритики
+	suggestions = s.SuggestAuthors(context.Background(), "emi", 10)
+	if len(suggestions) != 1 || suggestions[0].Author != "Émile Zola" {
+		t.Errorf("Ожидался 'Émile Zola', получено: %+v", suggestions)
+	}
+
+	// Тест 3: Ограничение количества результатов
+	suggestions = s.SuggestAuthors(context.Background(), "", 1)
+	if len(suggestions) != 1 {
+		t.Errorf("Ожидался 1 автор, получено: %+v", suggestions)
+	}
+
+	// Тест 4: Индекс обновляется при удалении
+	if err := s.DeleteQuoteID(context.Background(), 3, 0, ""); err != nil {
+		t.Fatalf("DeleteQuoteID вернула ошибку: %v", err)
+	}
+	suggestions = s.SuggestAuthors(context.Background(), "лер", 10)
+	if len(suggestions) != 0 {
+		t.Errorf("Ожидалось 0 авторов после удаления, получено: %+v", suggestions)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := &storage.JSONStorage{IdCounter: 1}
+
+	quotes := []storage.Quote{
+		{Quote: "abcd", Author: "Author 1", Tags: []string{"life", "Love"}},
+		{Quote: "ab", Author: "Author 1", Tags: []string{"life"}},
+		{Quote: "abcdef", Author: "Author 2"},
+	}
+	for _, quote := range quotes {
+		s.Add(context.Background(), quote, "")
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := s.GetRandom(context.Background()); err != nil {
+			t.Fatalf("GetRandom вернула ошибку: %v", err)
+		}
+	}
+
+	stats := s.Stats(context.Background(), 10)
+	if stats.TotalQuotes != 3 || stats.DistinctAuthors != 2 {
+		t.Errorf("Ожидалось 3 цитаты и 2 автора, получено: %+v", stats)
+	}
+	if stats.AverageLength != 4 {
+		t.Errorf("Ожидалась средняя длина 4, получено: %v", stats.AverageLength)
+	}
+	if len(stats.TopTags) != 2 || stats.TopTags[0].Tag != "life" || stats.TopTags[0].Count != 2 {
+		t.Errorf("Некорректные теги: %+v", stats.TopTags)
+	}
+
+	served := 0
+	for _, quote := range stats.MostServed {
+		served += quote.Served
+	}
+	if served != 5 {
+		t.Errorf("Ожидалось 5 выдач случайных цитат, получено: %d", served)
+	}
+
+	added := 0
+	for _, count := range stats.AddedPerDay {
+		added += count
+	}
+	if added != 3 {
+		t.Errorf("Ожидалось 3 добавленные цитаты по дням, получено: %d", added)
+	}
+
+	// Счетчики обновляются при удалении
+	if err := s.DeleteQuoteID(context.Background(), 1, 0, ""); err != nil {
+		t.Fatalf("DeleteQuoteID вернула ошибку: %v", err)
+	}
+	stats = s.Stats(context.Background(), 10)
+	if stats.TotalQuotes != 2 || stats.AverageLength != 4 || len(stats.TopTags) != 1 {
+		t.Errorf("Некорректная статистика после удаления: %+v", stats)
+	}
+}
+
+func TestHistoryAndRevert(t *testing.T) {
+	log, err := logger.New(logger.Options{Outputs: []string{filepath.Join(t.TempDir(), "log.log")}})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	tempFile, err := os.CreateTemp("", "test_JSON*.json")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	s, err := storage.CreateJSONStorage(tempFile.Name(), log)
+	if err != nil {
+		t.Fatalf("CreateJSONStorage вернула ошибку: %v", err)
+	}
+
+	quote := s.Add(context.Background(), storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "alice")
+	if _, err = s.UpdateQuoteID(context.Background(), quote.ID, storage.Quote{Quote: "Quote 2", Author: "Author 1"}, 0, "bob"); err != nil {
+		t.Fatalf("UpdateQuoteID вернула ошибку: %v", err)
+	}
+
+	// Тест 1: История содержит создание и изменение
+	revisions, err := s.GetHistory(context.Background(), quote.ID, true)
+	if err != nil {
+		t.Fatalf("GetHistory вернула ошибку: %v", err)
+	}
+	if len(revisions) != 2 || revisions[1].Actor != "bob" || revisions[1].Action != storage.ActionUpdate {
+		t.Fatalf("Некорректная история: %+v", revisions)
+	}
+	if len(revisions[1].Diff) != 1 || revisions[1].Diff[0].Old != "Quote 1" || revisions[1].Diff[0].New != "Quote 2" {
+		t.Errorf("Некорректный diff: %+v", revisions[1].Diff)
+	}
+
+	// Тест 2: Откат к первой ревизии
+	reverted, err := s.Revert(context.Background(), quote.ID, 1, "alice")
+	if err != nil {
+		t.Fatalf("Revert вернула ошибку: %v", err)
+	}
+	if reverted.Quote != "Quote 1" {
+		t.Errorf("Ожидался текст 'Quote 1', получено: %+v", reverted)
+	}
+
+	// Тест 3: Восстановление удаленной цитаты
+	if err = s.DeleteQuoteID(context.Background(), quote.ID, 0, "bob"); err != nil {
+		t.Fatalf("DeleteQuoteID вернула ошибку: %v", err)
+	}
+	if _, err = s.Revert(context.Background(), quote.ID, 2, "alice"); err != nil {
+		t.Fatalf("Revert вернула ошибку: %v", err)
+	}
+	restored, err := s.GetQuote(context.Background(), quote.ID)
+	if err != nil || restored.Quote != "Quote 2" {
+		t.Errorf("Ожидалась восстановленная цитата 'Quote 2', получено: %+v, %v", restored, err)
+	}
+
+	// Тест 4: История сохраняется вместе с цитатами
+	if err = s.Save(context.Background(), tempFile.Name(), log); err != nil {
+		t.Fatalf("Save вернула ошибку: %v", err)
+	}
+	historyFile := strings.TrimSuffix(tempFile.Name(), ".json") + ".history.json"
+	defer os.Remove(historyFile)
+
+	loaded, err := storage.CreateJSONStorage(tempFile.Name(), log)
+	if err != nil {
+		t.Fatalf("CreateJSONStorage вернула ошибку: %v", err)
+	}
+	revisions, err = loaded.GetHistory(context.Background(), quote.ID, true)
+	if err != nil || len(revisions) != 5 {
+		t.Errorf("Ожидалось 5 ревизий после загрузки, получено: %+v, %v", revisions, err)
+	}
+
+	// Тест 5: Несуществующая ревизия
+	if _, err = loaded.Revert(context.Background(), quote.ID, 99, "alice"); !errors.Is(err, storage.ErrRevisionNotFound) {
+		t.Errorf("Ожидалась ошибка ErrRevisionNotFound, получено: %v", err)
+	}
+
+	// Тест 6: У цитаты из файла без истории история пустая
+	legacy := filepath.Join(t.TempDir(), "quotes.json")
+	if err = os.WriteFile(legacy, []byte(`[{"id": 1, "quote": "Quote", "author": "Author"}]`), 0644); err != nil {
+		t.Fatalf("Не удалось записать файл: %v", err)
+	}
+	loaded, err = storage.CreateJSONStorage(legacy, log)
+	if err != nil {
+		t.Fatalf("CreateJSONStorage вернула ошибку: %v", err)
+	}
+	if revisions, err = loaded.GetHistory(context.Background(), 1, true); err != nil || revisions == nil || len(revisions) != 0 {
+		t.Errorf("Ожидалась пустая история, получено: %+v, %v", revisions, err)
+	}
+	if _, err = loaded.GetHistory(context.Background(), 2, true); !errors.Is(err, storage.ErrQuoteNotFound) {
+		t.Errorf("Ожидалась ошибка ErrQuoteNotFound, получено: %v", err)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	s, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	ctx := context.Background()
+
+	s.Add(ctx, storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "test")
+	s.Add(ctx, storage.Quote{Quote: "Quote 2", Author: "Author 2"}, "test")
+
+	// Тест 1: Имена пользователей уникальны без учета регистра
+	alice, err := s.Register(ctx, "alice", "hash-a")
+	if err != nil {
+		t.Fatalf("Register вернула ошибку: %v", err)
+	}
+	if _, err = s.Register(ctx, "Alice", "hash-b"); !errors.Is(err, storage.ErrUserExists) {
+		t.Errorf("Ожидалась ошибка ErrUserExists, получено: %v", err)
+	}
+	bob, _ := s.Register(ctx, "bob", "hash-b")
+
+	// Тест 2: Избранное хранит порядок и не дублирует цитаты
+	for _, id := range []int{2, 1, 2} {
+		if err = s.AddFavorite(ctx, alice.ID, id); err != nil {
+			t.Fatalf("AddFavorite вернула ошибку: %v", err)
+		}
+	}
+	if err = s.AddFavorite(ctx, alice.ID, 99); !errors.Is(err, storage.ErrQuoteNotFound) {
+		t.Errorf("Ожидалась ошибка ErrQuoteNotFound, получено: %v", err)
+	}
+	favorites, _ := s.Favorites(ctx, alice.ID)
+	if len(favorites) != 2 || favorites[0].ID != 2 || favorites[1].ID != 1 {
+		t.Errorf("Неожиданное избранное: %+v", favorites)
+	}
+
+	// Тест 3: Удаленная цитата не показывается, но остается в избранном
+	s.DeleteQuoteID(ctx, 2, 0, "test")
+	if favorites, _ = s.Favorites(ctx, alice.ID); len(favorites) != 1 {
+		t.Errorf("Удаленная цитата не должна показываться: %+v", favorites)
+	}
+	s.Restore(ctx, 2, "test")
+
+	// Тест 4: Коллекции видны только владельцу
+	collection, err := s.CreateCollection(ctx, alice.ID, "Любимое")
+	if err != nil {
+		t.Fatalf("CreateCollection вернула ошибку: %v", err)
+	}
+	if _, err = s.CreateCollection(ctx, alice.ID, "любимое"); !errors.Is(err, storage.ErrCollectionExists) {
+		t.Errorf("Ожидалась ошибка ErrCollectionExists, получено: %v", err)
+	}
+	if _, err = s.AddToCollection(ctx, alice.ID, collection.ID, 1); err != nil {
+		t.Fatalf("AddToCollection вернула ошибку: %v", err)
+	}
+	if _, err = s.GetCollection(ctx, bob.ID, collection.ID); !errors.Is(err, storage.ErrCollectionNotFound) {
+		t.Errorf("Чужая коллекция должна быть не найдена, получено: %v", err)
+	}
+
+	// Тест 5: Сессия действует до истечения срока и до выхода
+	if _, err = s.CreateSession(ctx, alice.ID, "expired", -time.Minute); err != nil {
+		t.Fatalf("CreateSession вернула ошибку: %v", err)
+	}
+	if _, err = s.CreateSession(ctx, alice.ID, "active", time.Hour); err != nil {
+		t.Fatalf("CreateSession вернула ошибку: %v", err)
+	}
+	if _, err = s.Session(ctx, "expired"); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("Истекшая сессия должна отклоняться, получено: %v", err)
+	}
+	if user, err := s.Session(ctx, "active"); err != nil || user.ID != alice.ID {
+		t.Errorf("Ожидалась сессия alice, получено: %+v, %v", user, err)
+	}
+
+	// Тест 6: Пользователи, избранное, коллекции и сессии сохраняются в файл
+	if err = s.Save(ctx, path, log); err != nil {
+		t.Fatalf("Save вернула ошибку: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(filepath.Dir(path), "quotes.users.json")); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Ожидался файл пользователей с правами 0600: %v", err)
+	}
+
+	reloaded, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось перечитать хранилище: %v", err)
+	}
+	if _, hash, err := reloaded.Credentials(ctx, "ALICE"); err != nil || hash != "hash-a" {
+		t.Errorf("Ожидался хеш пароля alice, получено: %q, %v", hash, err)
+	}
+	if favorites, _ = reloaded.Favorites(ctx, alice.ID); len(favorites) != 2 {
+		t.Errorf("Избранное не сохранилось: %+v", favorites)
+	}
+	if got, err := reloaded.GetCollection(ctx, alice.ID, collection.ID); err != nil || len(got.Quotes) != 1 {
+		t.Errorf("Коллекция не сохранилась: %+v, %v", got, err)
+	}
+	if err = reloaded.DeleteSession(ctx, "active"); err != nil {
+		t.Errorf("Сессия не сохранилась: %v", err)
+	}
+}
+
+func TestRatings(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	s, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		s.Add(ctx, storage.Quote{Quote: fmt.Sprintf("Quote %d", i), Author: "Author"}, "test")
+	}
+
+	// Тест 1: Лайк одного клиента учитывается один раз и не меняет версию
+	s.Like(ctx, 1, "user:1")
+	quote, err := s.Like(ctx, 1, "user:1")
+	if err != nil || quote.Likes != 1 || quote.Version != 1 {
+		t.Errorf("Ожидался один лайк без смены версии, получено: %+v, %v", quote, err)
+	}
+	s.Like(ctx, 1, "ip:192.0.2.1")
+	if quote, _ = s.Unlike(ctx, 1, "user:1"); quote.Likes != 1 {
+		t.Errorf("Ожидался один лайк после отмены, получено: %d", quote.Likes)
+	}
+
+	// Тест 2: Повторная оценка заменяет прежнюю
+	s.Rate(ctx, 2, "user:1", 1)
+	quote, err = s.Rate(ctx, 2, "user:1", 5)
+	if err != nil || quote.RatingCount != 1 || quote.RatingAverage != 5 {
+		t.Errorf("Ожидалась одна оценка 5, получено: %+v, %v", quote, err)
+	}
+	if _, err = s.Rate(ctx, 2, "user:1", 6); !errors.Is(err, storage.ErrInvalidRating) {
+		t.Errorf("Ожидалась ошибка ErrInvalidRating, получено: %v", err)
+	}
+
+	// Тест 3: Байесовская оценка ставит много четверок выше одной пятерки
+	for i := 0; i < 10; i++ {
+		s.Rate(ctx, 1, fmt.Sprintf("user:%d", i+10), 3)
+		s.Rate(ctx, 3, fmt.Sprintf("user:%d", i+10), 4)
+	}
+	top := s.TopRated(ctx, 10)
+	if len(top) != 3 || top[0].ID != 3 || top[1].ID != 2 || top[2].ID != 1 || top[1].Score >= 5 {
+		t.Errorf("Неожиданный рейтинг: %+v", top)
+	}
+
+	// Тест 4: Голоса сохраняются и пересчитываются при загрузке
+	if err = s.Save(ctx, path, log); err != nil {
+		t.Fatalf("Save вернула ошибку: %v", err)
+	}
+	reloaded, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось перечитать хранилище: %v", err)
+	}
+	if quote, _ = reloaded.GetQuote(ctx, 3); quote.RatingCount != 10 || quote.RatingAverage != 4 {
+		t.Errorf("Оценки не сохранились: %+v", quote)
+	}
+	if quote, _ = reloaded.Like(ctx, 1, "ip:192.0.2.1"); quote.Likes != 1 {
+		t.Errorf("Лайки не сохранились: %+v", quote)
+	}
+
+	// Тест 5: Голоса окончательно удаленной цитаты удаляются
+	reloaded.DeleteQuoteID(ctx, 3, 0, "test")
+	reloaded.PurgeTrash(ctx, 0)
+	if top = reloaded.TopRated(ctx, 10); len(top) != 2 || top[0].ID != 2 {
+		t.Errorf("Ожидались две оцененные цитаты, получено: %+v", top)
+	}
+}
+
+func TestModeration(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+
+	s, err := storage.CreateJSONStorage(filepath.Join(t.TempDir(), "quotes.json"), log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	ctx := context.Background()
+
+	s.Add(ctx, storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "editor")
+	pending := s.Submit(ctx, storage.Quote{Quote: "Quote 2", Author: "Author 2", Owner: "user:2"}, "alice")
+	spam := s.Submit(ctx, storage.Quote{Quote: "Spam", Author: "Spam"}, "bob")
+
+	// Тест 1: Цитаты на модерации не видны читающим методам
+	if quotes, _ := s.GetQuotes(ctx); len(quotes) != 1 {
+		t.Errorf("Ожидалась одна видимая цитата, получено: %+v", quotes)
+	}
+	if _, err = s.GetQuote(ctx, pending.ID); !errors.Is(err, storage.ErrQuoteNotFound) {
+		t.Errorf("Цитата на модерации не должна быть видна, получено: %v", err)
+	}
+	if queue := s.ModerationQueue(ctx); len(queue) != 2 || queue[0].ID != pending.ID {
+		t.Errorf("Неожиданная очередь модерации: %+v", queue)
+	}
+
+	// Тест 2: Одобренная цитата публикуется
+	approved, err := s.Approve(ctx, pending.ID, "carol")
+	if err != nil || approved.Status != storage.StatusApproved || approved.Moderation.Actor != "carol" {
+		t.Fatalf("Ожидалось одобрение, получено: %+v, %v", approved, err)
+	}
+	if _, err = s.GetQuote(ctx, pending.ID); err != nil {
+		t.Errorf("Одобренная цитата должна быть видна, получено: %v", err)
+	}
+
+	// Тест 3: Отклоненная цитата остается скрытой с причиной
+	rejected, err := s.Reject(ctx, spam.ID, "carol", "реклама")
+	if err != nil || rejected.Status != storage.StatusRejected || rejected.Moderation.Reason != "реклама" {
+		t.Fatalf("Ожидалось отклонение, получено: %+v, %v", rejected, err)
+	}
+	if quotes, _ := s.GetQuotes(ctx); len(quotes) != 2 {
+		t.Errorf("Ожидалось две видимые цитаты, получено: %+v", quotes)
+	}
+
+	// Тест 4: Повторная модерация невозможна
+	if _, err = s.Approve(ctx, spam.ID, "carol"); !errors.Is(err, storage.ErrQuoteNotFound) {
+		t.Errorf("Ожидалась ошибка ErrQuoteNotFound, получено: %v", err)
+	}
+	if queue := s.ModerationQueue(ctx); len(queue) != 0 {
+		t.Errorf("Очередь модерации должна быть пустой: %+v", queue)
+	}
+
+	// Тест 5: Решения модератора записываются в историю
+	history, _ := s.GetHistory(ctx, spam.ID, true)
+	if len(history) != 2 || history[0].Action != storage.ActionSubmit || history[1].Action != storage.ActionReject {
+		t.Errorf("Неожиданная история: %+v", history)
+	}
+
+	// Тест 6: Откат окончательно удаленной цитаты сохраняет решение модератора и владельца
+	if err = s.DeleteQuoteID(ctx, pending.ID, 0, "carol"); err != nil {
+		t.Fatalf("DeleteQuoteID вернула ошибку: %v", err)
+	}
+	s.PurgeTrash(ctx, 0)
+	reverted, err := s.Revert(ctx, pending.ID, 1, "carol")
+	if err != nil {
+		t.Fatalf("Revert вернула ошибку: %v", err)
+	}
+	if reverted.Status != storage.StatusApproved || reverted.Owner != "user:2" || reverted.SubmittedBy != "alice" || reverted.Moderation == nil || reverted.Moderation.Actor != "carol" {
+		t.Errorf("Ожидалась одобренная цитата alice, получено: %+v", reverted)
+	}
+}
+
+func TestReports(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать логгер: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	s, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	ctx := context.Background()
+
+	quote := s.Add(ctx, storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "test")
+	other := s.Add(ctx, storage.Quote{Quote: "Quote 2", Author: "Author 2"}, "test")
+
+	// Тест 1: Жалоба с неизвестной категорией отклоняется
+	if _, err = s.Report(ctx, quote.ID, "user:1", false, "boring", "", 2); !errors.Is(err, storage.ErrInvalidReport) {
+		t.Errorf("Ожидалась ошибка ErrInvalidReport, получено: %v", err)
+	}
+
+	// Тест 2: Повторная жалоба того же клиента отклоняется
+	if _, err = s.Report(ctx, quote.ID, "user:1", false, storage.ReportMisattributed, "Это не его слова", 2); err != nil {
+		t.Fatalf("Не удалось отправить жалобу: %v", err)
+	}
+	if _, err = s.Report(ctx, quote.ID, "user:1", false, storage.ReportSpam, "", 2); !errors.Is(err, storage.ErrDuplicateReport) {
+		t.Errorf("Ожидалась ошибка ErrDuplicateReport, получено: %v", err)
+	}
+
+	// Тест 3: Анонимная жалоба видна администраторам, но в пороге не учитывается
+	summary, err := s.Report(ctx, quote.ID, "ip:192.0.2.1", true, storage.ReportSpam, "", 2)
+	if err != nil || summary.Open != 2 || summary.Quote.Hidden {
+		t.Fatalf("Ожидалась жалоба без скрытия цитаты, получено: %+v, %v", summary, err)
+	}
+
+	// Тест 4: При достижении порога цитата скрывается
+	summary, err = s.Report(ctx, quote.ID, "key:2", false, storage.ReportOffensive, "", 2)
+	if err != nil || summary.Open != 3 || !summary.Quote.Hidden {
+		t.Fatalf("Ожидалось скрытие цитаты, получено: %+v, %v", summary, err)
+	}
+	if _, err = s.GetQuote(ctx, quote.ID); !errors.Is(err, storage.ErrQuoteNotFound) {
+		t.Errorf("Скрытая цитата не должна быть видна, получено: %v", err)
+	}
+	if _, err = s.Report(ctx, quote.ID, "key:3", false, storage.ReportSpam, "", 2); !errors.Is(err, storage.ErrQuoteNotFound) {
+		t.Errorf("На скрытую цитату нельзя пожаловаться, получено: %v", err)
+	}
+
+	// Тест 5: Жалобы сводятся по цитатам, самые обсуждаемые первыми
+	if _, err = s.Report(ctx, other.ID, "user:1", false, storage.ReportDuplicate, "", 0); err != nil {
+		t.Fatalf("Не удалось отправить жалобу: %v", err)
+	}
+	reports := s.Reports(ctx, "open")
+	if len(reports) != 2 || reports[0].QuoteID != quote.ID || reports[0].Categories[storage.ReportOffensive] != 1 {
+		t.Errorf("Неожиданная сводка жалоб: %+v", reports)
+	}
+
+	// Тест 6: Отклонение жалоб снова показывает цитату
+	summary, err = s.ResolveReports(ctx, quote.ID, storage.ResolutionDismiss, "admin", "Автор верный")
+	if err != nil || summary.Open != 0 || summary.Quote.Hidden || summary.Reports[0].ResolvedBy != "admin" {
+		t.Fatalf("Ожидалось отклонение жалоб, получено: %+v, %v", summary, err)
+	}
+	if _, err = s.GetQuote(ctx, quote.ID); err != nil {
+		t.Errorf("Цитата должна снова быть видна, получено: %v", err)
+	}
+	if _, err = s.ResolveReports(ctx, quote.ID, storage.ResolutionDismiss, "admin", ""); !errors.Is(err, storage.ErrReportNotFound) {
+		t.Errorf("Ожидалась ошибка ErrReportNotFound, получено: %v", err)
+	}
+	if reports = s.Reports(ctx, "resolved"); len(reports) != 1 || reports[0].QuoteID != quote.ID {
+		t.Errorf("Неожиданная сводка рассмотренных жалоб: %+v", reports)
+	}
+
+	// Тест 7: Удаление по жалобе переносит цитату в корзину
+	if _, err = s.ResolveReports(ctx, other.ID, storage.ResolutionRemove, "admin", ""); err != nil {
+		t.Fatalf("Не удалось удалить цитату по жалобе: %v", err)
+	}
+	if trash := s.GetTrash(ctx); len(trash) != 1 || trash[0].ID != other.ID {
+		t.Errorf("Ожидалась цитата в корзине, получено: %+v", trash)
+	}
+
+	// Тест 8: Скрытие и рассмотрение записываются в историю
+	history, _ := s.GetHistory(ctx, quote.ID, true)
+	if len(history) != 3 || history[1].Action != storage.ActionHide || history[2].Action != storage.ActionUnhide {
+		t.Errorf("Неожиданная история: %+v", history)
+	}
+
+	// Тест 9: Жалобы сохраняются между перезапусками
+	if err = s.Save(ctx, path, log); err != nil {
+		t.Fatalf("Не удалось сохранить хранилище: %v", err)
+	}
+	reloaded, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось загрузить хранилище: %v", err)
+	}
+	if reports = reloaded.Reports(ctx, "all"); len(reports) != 2 {
+		t.Errorf("Ожидались жалобы на две цитаты, получено: %+v", reports)
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать логгер: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "quotes.json")
+	s, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось инициализировать хранилище: %v", err)
+	}
+	ctx := context.Background()
+
+	quote := s.Add(ctx, storage.Quote{Quote: "Все счастливые семьи похожи друг на друга", Author: "Лев Толстой", Language: "ru"}, "test")
+
+	// Тест 1: Перевод добавляется и меняет версию цитаты
+	translation := storage.Translation{Quote: "All happy families are alike", Author: "Leo Tolstoy"}
+	updated, err := s.SetTranslation(ctx, quote.ID, "en", translation, quote.Version, "alice")
+	if err != nil || updated.Version != 2 || updated.Translations["en"].UpdatedBy != "alice" {
+		t.Fatalf("Ожидался перевод на en, получено: %+v, %v", updated, err)
+	}
+	if quote.Translations != nil {
+		t.Errorf("Перевод не должен менять прежнюю копию цитаты: %+v", quote.Translations)
+	}
+
+	// Тест 2: Перевод на язык оригинала и устаревшая версия отклоняются
+	if _, err = s.SetTranslation(ctx, quote.ID, "ru", translation, 0, "alice"); !errors.Is(err, storage.ErrOriginalLanguage) {
+		t.Errorf("Ожидалась ошибка ErrOriginalLanguage, получено: %v", err)
+	}
+	if _, err = s.SetTranslation(ctx, quote.ID, "de", translation, 1, "alice"); !errors.Is(err, storage.ErrVersionMismatch) {
+		t.Errorf("Ожидалась ошибка ErrVersionMismatch, получено: %v", err)
+	}
+
+	// Тест 3: Переводы попадают в историю
+	history, _ := s.GetHistory(ctx, quote.ID, true)
+	if len(history) != 2 || history[1].Action != storage.ActionTranslate || history[1].Diff[0].Field != "translation:en" {
+		t.Errorf("Неожиданная история: %+v", history)
+	}
+
+	// Тест 4: Язык и переводы сохраняются между перезапусками
+	if err = s.Save(ctx, path, log); err != nil {
+		t.Fatalf("Не удалось сохранить хранилище: %v", err)
+	}
+	reloaded, err := storage.CreateJSONStorage(path, log)
+	if err != nil {
+		t.Fatalf("Не удалось загрузить хранилище: %v", err)
+	}
+	saved, err := reloaded.GetQuote(ctx, quote.ID)
+	if err != nil || saved.Language != "ru" || saved.Translations["en"].Quote != translation.Quote {
+		t.Errorf("Перевод не сохранился: %+v, %v", saved, err)
+	}
+
+	// Тест 5: Удаление перевода
+	if updated, err = s.DeleteTranslation(ctx, quote.ID, "en", 0, "alice"); err != nil || updated.Translations != nil {
+		t.Errorf("Ожидалось удаление перевода, получено: %+v, %v", updated, err)
+	}
+	if _, err = s.DeleteTranslation(ctx, quote.ID, "en", 0, "alice"); !errors.Is(err, storage.ErrTranslationNotFound) {
+		t.Errorf("Ожидалась ошибка ErrTranslationNotFound, получено: %v", err)
+	}
+}
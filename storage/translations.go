@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+var (
+	ErrTranslationNotFound = errors.New("Перевод не найден")
+	ErrOriginalLanguage    = errors.New("Язык перевода совпадает с языком оригинала")
+)
+
+// SetTranslation добавляет или заменяет перевод цитаты на язык lang. Версия
+// проверяется как в UpdateQuoteID.
+func (storage *JSONStorage) SetTranslation(ctx context.Context, id int, lang string, translation Translation, version int, actor string) (QuoteStore, error) {
+	defer storage.begin(ctx, "set_translation")()
+
+	i, after, err := storage.translate(id, lang, translation, version, actor)
+	if err != nil {
+		return QuoteStore{}, err
+	}
+
+	before := storage.Quotes[i]
+	after = storage.replace(i, after)
+	storage.record(ActionTranslate, before, after, actor)
+
+	return after, nil
+}
+
+// translate находит цитату для SetTranslation и SubmitTranslation и
+// возвращает ее позицию и копию с переводом. Вызывается под storage.mute.
+func (storage *JSONStorage) translate(id int, lang string, translation Translation, version int, actor string) (int, QuoteStore, error) {
+	i, err := storage.findVersion(id, version)
+	if err != nil {
+		return -1, QuoteStore{}, err
+	}
+	if lang == storage.Quotes[i].Language {
+		return -1, QuoteStore{}, fmt.Errorf("%w: %s", ErrOriginalLanguage, lang)
+	}
+
+	translation.UpdatedAt = time.Now()
+	translation.UpdatedBy = actor
+
+	after := storage.Quotes[i]
+	after.Translations = maps.Clone(after.Translations)
+	if after.Translations == nil {
+		after.Translations = make(map[string]Translation)
+	}
+	after.Translations[lang] = translation
+
+	return i, after, nil
+}
+
+// DeleteTranslation удаляет перевод цитаты на язык lang.
+func (storage *JSONStorage) DeleteTranslation(ctx context.Context, id int, lang string, version int, actor string) (QuoteStore, error) {
+	defer storage.begin(ctx, "delete_translation")()
+
+	i, err := storage.findVersion(id, version)
+	if err != nil {
+		return QuoteStore{}, err
+	}
+	if _, ok := storage.Quotes[i].Translations[lang]; !ok {
+		return QuoteStore{}, fmt.Errorf("%w: ID %d, язык %s", ErrTranslationNotFound, id, lang)
+	}
+
+	before := storage.Quotes[i]
+	after := before
+	after.Translations = maps.Clone(before.Translations)
+	delete(after.Translations, lang)
+	if len(after.Translations) == 0 {
+		after.Translations = nil
+	}
+
+	after = storage.replace(i, after)
+	storage.record(ActionTranslate, before, after, actor)
+
+	return after, nil
+}
+
+// text возвращает перевод одной строкой для истории изменений.
+func (translation Translation) text() string {
+	if translation.Author == "" {
+		return translation.Quote
+	}
+	return translation.Quote + " — " + translation.Author
+}
+
+// translationLanguages возвращает языки переводов обеих версий цитаты по
+// алфавиту.
+func translationLanguages(before, after QuoteStore) []string {
+	langs := slices.Collect(maps.Keys(before.Translations))
+	for lang := range after.Translations {
+		if !slices.Contains(langs, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	slices.Sort(langs)
+	return langs
+}
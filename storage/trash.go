@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (storage *JSONStorage) GetTrash(ctx context.Context) []QuoteStore {
+	defer storage.begin(ctx, "get_trash")()
+
+	trash := []QuoteStore{}
+	for _, quote := range storage.Quotes {
+		if quote.DeletedAt != nil {
+			trash = append(trash, quote)
+		}
+	}
+
+	return trash
+}
+
+func (storage *JSONStorage) Restore(ctx context.Context, id int, actor string) (QuoteStore, error) {
+	defer storage.begin(ctx, "restore")()
+
+	i := storage.find(id)
+	if i < 0 || storage.Quotes[i].DeletedAt == nil {
+		return QuoteStore{}, fmt.Errorf("%w в корзине: ID %d", ErrQuoteNotFound, id)
+	}
+
+	before := storage.Quotes[i]
+	after := before
+	after.DeletedAt = nil
+
+	after = storage.replace(i, after)
+	storage.record(ActionRestore, before, after, actor)
+
+	return after, nil
+}
+
+// PurgeTrash окончательно удаляет цитаты, пролежавшие в корзине дольше
+// retention, и возвращает их количество.
+func (storage *JSONStorage) PurgeTrash(ctx context.Context, retention time.Duration) int {
+	defer storage.begin(ctx, "purge_trash")()
+
+	deadline := time.Now().Add(-retention)
+	purged := 0
+
+	for i := len(storage.Quotes) - 1; i >= 0; i-- {
+		quote := storage.Quotes[i]
+		if quote.DeletedAt == nil || quote.DeletedAt.After(deadline) {
+			continue
+		}
+
+		storage.removeAt(i)
+		storage.record(ActionPurge, quote, QuoteStore{}, "system")
+		purged++
+	}
+
+	return purged
+}
@@ -0,0 +1,481 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUserExists         = errors.New("Пользователь уже существует")
+	ErrUserNotFound       = errors.New("Пользователь не найден")
+	ErrSessionNotFound    = errors.New("Сессия не найдена или истекла")
+	ErrCollectionExists   = errors.New("Коллекция с таким именем уже существует")
+	ErrCollectionNotFound = errors.New("Коллекция не найдена")
+)
+
+// User учетная запись пользователя. Хеш пароля хранится отдельно в
+// UserStore и наружу не отдается.
+type User struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	// Favorites ID избранных цитат в порядке добавления.
+	Favorites []int `json:"favorites"`
+}
+
+type UserStore struct {
+	User
+	PasswordHash string `json:"password_hash"`
+}
+
+// Collection именованная подборка цитат пользователя.
+type Collection struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	QuoteIDs  []int     `json:"quote_ids"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CollectionQuotes коллекция вместе с ее видимыми цитатами.
+type CollectionQuotes struct {
+	Collection
+	Quotes []QuoteStore `json:"quotes"`
+}
+
+// Session сессия пользователя. Ключом в хранилище служит хеш токена.
+type Session struct {
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// accounts пользователи, их сессии и коллекции. Хранятся в отдельном файле
+// рядом с файлом цитат.
+type accounts struct {
+	Users       []UserStore        `json:"users"`
+	Collections []Collection       `json:"collections"`
+	Sessions    map[string]Session `json:"sessions"`
+}
+
+// accountsPath возвращает путь к файлу пользователей рядом с файлом цитат:
+// ./storage/quotes.json -> ./storage/quotes.users.json.
+func accountsPath(filename string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + ".users" + ext
+}
+
+// Register создает пользователя с уже вычисленным хешем пароля. Имена
+// сравниваются без учета регистра.
+func (storage *JSONStorage) Register(ctx context.Context, username, passwordHash string) (User, error) {
+	defer storage.begin(ctx, "register")()
+
+	if storage.findUserByName(username) >= 0 {
+		return User{}, fmt.Errorf("%w: %s", ErrUserExists, username)
+	}
+
+	user := UserStore{
+		User: User{
+			ID:        storage.nextUserID(),
+			Username:  username,
+			CreatedAt: time.Now(),
+			Favorites: []int{},
+		},
+		PasswordHash: passwordHash,
+	}
+	storage.accounts.Users = append(storage.accounts.Users, user)
+	storage.markDirty()
+
+	return user.User, nil
+}
+
+// Credentials возвращает пользователя и хеш его пароля для проверки при входе.
+func (storage *JSONStorage) Credentials(ctx context.Context, username string) (User, string, error) {
+	defer storage.begin(ctx, "credentials")()
+
+	i := storage.findUserByName(username)
+	if i < 0 {
+		return User{}, "", fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+
+	user := storage.accounts.Users[i]
+	return copyUser(user.User), user.PasswordHash, nil
+}
+
+func (storage *JSONStorage) GetUser(ctx context.Context, id int) (User, error) {
+	defer storage.begin(ctx, "get_user")()
+
+	i := storage.findUser(id)
+	if i < 0 {
+		return User{}, fmt.Errorf("%w: ID %d", ErrUserNotFound, id)
+	}
+
+	return copyUser(storage.accounts.Users[i].User), nil
+}
+
+// CreateSession сохраняет сессию пользователя под хешем ее токена и
+// заодно удаляет истекшие сессии.
+func (storage *JSONStorage) CreateSession(ctx context.Context, userID int, tokenHash string, ttl time.Duration) (Session, error) {
+	defer storage.begin(ctx, "create_session")()
+
+	if storage.findUser(userID) < 0 {
+		return Session{}, fmt.Errorf("%w: ID %d", ErrUserNotFound, userID)
+	}
+
+	now := time.Now()
+	for hash, session := range storage.accounts.Sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(storage.accounts.Sessions, hash)
+		}
+	}
+
+	session := Session{UserID: userID, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+	if storage.accounts.Sessions == nil {
+		storage.accounts.Sessions = make(map[string]Session)
+	}
+	storage.accounts.Sessions[tokenHash] = session
+	storage.markDirty()
+
+	return session, nil
+}
+
+// Session возвращает пользователя действующей сессии.
+func (storage *JSONStorage) Session(ctx context.Context, tokenHash string) (User, error) {
+	defer storage.begin(ctx, "session")()
+
+	session, ok := storage.accounts.Sessions[tokenHash]
+	if !ok || !time.Now().Before(session.ExpiresAt) {
+		return User{}, ErrSessionNotFound
+	}
+
+	i := storage.findUser(session.UserID)
+	if i < 0 {
+		return User{}, ErrSessionNotFound
+	}
+
+	return copyUser(storage.accounts.Users[i].User), nil
+}
+
+func (storage *JSONStorage) DeleteSession(ctx context.Context, tokenHash string) error {
+	defer storage.begin(ctx, "delete_session")()
+
+	if _, ok := storage.accounts.Sessions[tokenHash]; !ok {
+		return ErrSessionNotFound
+	}
+	delete(storage.accounts.Sessions, tokenHash)
+	storage.markDirty()
+
+	return nil
+}
+
+// Favorites возвращает видимые избранные цитаты пользователя в порядке
+// добавления. Удаленные цитаты пропускаются, но остаются в избранном и
+// вернутся в него после восстановления.
+func (storage *JSONStorage) Favorites(ctx context.Context, userID int) ([]QuoteStore, error) {
+	defer storage.begin(ctx, "favorites")()
+
+	i := storage.findUser(userID)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: ID %d", ErrUserNotFound, userID)
+	}
+
+	return storage.visibleQuotes(storage.accounts.Users[i].Favorites), nil
+}
+
+// AddFavorite добавляет цитату в избранное. Повторное добавление ничего не
+// меняет.
+func (storage *JSONStorage) AddFavorite(ctx context.Context, userID, quoteID int) error {
+	defer storage.begin(ctx, "add_favorite")()
+
+	i := storage.findUser(userID)
+	if i < 0 {
+		return fmt.Errorf("%w: ID %d", ErrUserNotFound, userID)
+	}
+	if storage.findVisible(quoteID) < 0 {
+		return fmt.Errorf("%w: ID %d", ErrQuoteNotFound, quoteID)
+	}
+
+	user := &storage.accounts.Users[i]
+	if !slices.Contains(user.Favorites, quoteID) {
+		user.Favorites = append(user.Favorites, quoteID)
+		storage.markDirty()
+	}
+
+	return nil
+}
+
+func (storage *JSONStorage) RemoveFavorite(ctx context.Context, userID, quoteID int) error {
+	defer storage.begin(ctx, "remove_favorite")()
+
+	i := storage.findUser(userID)
+	if i < 0 {
+		return fmt.Errorf("%w: ID %d", ErrUserNotFound, userID)
+	}
+
+	user := &storage.accounts.Users[i]
+	j := slices.Index(user.Favorites, quoteID)
+	if j < 0 {
+		return fmt.Errorf("%w в избранном: ID %d", ErrQuoteNotFound, quoteID)
+	}
+	user.Favorites = slices.Delete(user.Favorites, j, j+1)
+	storage.markDirty()
+
+	return nil
+}
+
+func (storage *JSONStorage) Collections(ctx context.Context, userID int) []Collection {
+	defer storage.begin(ctx, "collections")()
+
+	collections := []Collection{}
+	for _, collection := range storage.accounts.Collections {
+		if collection.UserID == userID {
+			collections = append(collections, copyCollection(collection))
+		}
+	}
+
+	return collections
+}
+
+// CreateCollection создает пустую коллекцию. Имена коллекций одного
+// пользователя не повторяются без учета регистра.
+func (storage *JSONStorage) CreateCollection(ctx context.Context, userID int, name string) (Collection, error) {
+	defer storage.begin(ctx, "create_collection")()
+
+	if storage.findUser(userID) < 0 {
+		return Collection{}, fmt.Errorf("%w: ID %d", ErrUserNotFound, userID)
+	}
+	if storage.findCollectionByName(userID, name) >= 0 {
+		return Collection{}, fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+
+	now := time.Now()
+	collection := Collection{
+		ID:        storage.nextCollectionID(),
+		UserID:    userID,
+		Name:      name,
+		QuoteIDs:  []int{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	storage.accounts.Collections = append(storage.accounts.Collections, collection)
+	storage.markDirty()
+
+	return copyCollection(collection), nil
+}
+
+// GetCollection возвращает коллекцию пользователя с ее видимыми цитатами.
+// Чужая коллекция считается ненайденной.
+func (storage *JSONStorage) GetCollection(ctx context.Context, userID, id int) (CollectionQuotes, error) {
+	defer storage.begin(ctx, "get_collection")()
+
+	i, err := storage.findCollection(userID, id)
+	if err != nil {
+		return CollectionQuotes{}, err
+	}
+
+	collection := storage.accounts.Collections[i]
+	return CollectionQuotes{
+		Collection: copyCollection(collection),
+		Quotes:     storage.visibleQuotes(collection.QuoteIDs),
+	}, nil
+}
+
+func (storage *JSONStorage) RenameCollection(ctx context.Context, userID, id int, name string) (Collection, error) {
+	defer storage.begin(ctx, "rename_collection")()
+
+	i, err := storage.findCollection(userID, id)
+	if err != nil {
+		return Collection{}, err
+	}
+	if j := storage.findCollectionByName(userID, name); j >= 0 && j != i {
+		return Collection{}, fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+
+	collection := &storage.accounts.Collections[i]
+	collection.Name = name
+	collection.UpdatedAt = time.Now()
+	storage.markDirty()
+
+	return copyCollection(*collection), nil
+}
+
+func (storage *JSONStorage) DeleteCollection(ctx context.Context, userID, id int) error {
+	defer storage.begin(ctx, "delete_collection")()
+
+	i, err := storage.findCollection(userID, id)
+	if err != nil {
+		return err
+	}
+	storage.accounts.Collections = slices.Delete(storage.accounts.Collections, i, i+1)
+	storage.markDirty()
+
+	return nil
+}
+
+// AddToCollection добавляет цитату в коллекцию. Повторное добавление ничего
+// не меняет.
+func (storage *JSONStorage) AddToCollection(ctx context.Context, userID, id, quoteID int) (Collection, error) {
+	defer storage.begin(ctx, "add_to_collection")()
+
+	i, err := storage.findCollection(userID, id)
+	if err != nil {
+		return Collection{}, err
+	}
+	if storage.findVisible(quoteID) < 0 {
+		return Collection{}, fmt.Errorf("%w: ID %d", ErrQuoteNotFound, quoteID)
+	}
+
+	collection := &storage.accounts.Collections[i]
+	if !slices.Contains(collection.QuoteIDs, quoteID) {
+		collection.QuoteIDs = append(collection.QuoteIDs, quoteID)
+		collection.UpdatedAt = time.Now()
+		storage.markDirty()
+	}
+
+	return copyCollection(*collection), nil
+}
+
+func (storage *JSONStorage) RemoveFromCollection(ctx context.Context, userID, id, quoteID int) (Collection, error) {
+	defer storage.begin(ctx, "remove_from_collection")()
+
+	i, err := storage.findCollection(userID, id)
+	if err != nil {
+		return Collection{}, err
+	}
+
+	collection := &storage.accounts.Collections[i]
+	j := slices.Index(collection.QuoteIDs, quoteID)
+	if j < 0 {
+		return Collection{}, fmt.Errorf("%w в коллекции: ID %d", ErrQuoteNotFound, quoteID)
+	}
+	collection.QuoteIDs = slices.Delete(collection.QuoteIDs, j, j+1)
+	collection.UpdatedAt = time.Now()
+	storage.markDirty()
+
+	return copyCollection(*collection), nil
+}
+
+// findUser возвращает позицию пользователя или -1. Вызывается под storage.mute.
+func (storage *JSONStorage) findUser(id int) int {
+	for i, user := range storage.accounts.Users {
+		if user.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// findUserByName вызывается под storage.mute.
+func (storage *JSONStorage) findUserByName(username string) int {
+	for i, user := range storage.accounts.Users {
+		if strings.EqualFold(user.Username, username) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findCollection возвращает позицию коллекции пользователя. Вызывается под
+// storage.mute.
+func (storage *JSONStorage) findCollection(userID, id int) (int, error) {
+	for i, collection := range storage.accounts.Collections {
+		if collection.ID == id && collection.UserID == userID {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%w: ID %d", ErrCollectionNotFound, id)
+}
+
+// findCollectionByName вызывается под storage.mute.
+func (storage *JSONStorage) findCollectionByName(userID int, name string) int {
+	for i, collection := range storage.accounts.Collections {
+		if collection.UserID == userID && strings.EqualFold(collection.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextUserID вызывается под storage.mute.
+func (storage *JSONStorage) nextUserID() int {
+	maxID := 0
+	for _, user := range storage.accounts.Users {
+		maxID = max(maxID, user.ID)
+	}
+	return maxID + 1
+}
+
+// nextCollectionID вызывается под storage.mute.
+func (storage *JSONStorage) nextCollectionID() int {
+	maxID := 0
+	for _, collection := range storage.accounts.Collections {
+		maxID = max(maxID, collection.ID)
+	}
+	return maxID + 1
+}
+
+// visibleQuotes возвращает видимые цитаты с указанными ID в том же порядке.
+// Вызывается под storage.mute.
+func (storage *JSONStorage) visibleQuotes(ids []int) []QuoteStore {
+	quotes := make([]QuoteStore, 0, len(ids))
+	for _, id := range ids {
+		if i := storage.findVisible(id); i >= 0 {
+			quotes = append(quotes, storage.Quotes[i])
+		}
+	}
+	return quotes
+}
+
+func copyUser(user User) User {
+	user.Favorites = slices.Clone(user.Favorites)
+	return user
+}
+
+func copyCollection(collection Collection) Collection {
+	collection.QuoteIDs = slices.Clone(collection.QuoteIDs)
+	return collection
+}
+
+func (storage *JSONStorage) loadAccounts(filename string) error {
+	data, err := os.ReadFile(accountsPath(filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("Не удалось прочитать файл пользователей: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &storage.accounts); err != nil {
+		return fmt.Errorf("Не удалось десериализовать файл пользователей: %w", err)
+	}
+
+	return nil
+}
+
+// saveAccounts вызывается под storage.mute. Файл содержит хеши паролей и
+// сессий, поэтому доступен только владельцу.
+func (storage *JSONStorage) saveAccounts(filename string) error {
+	path := accountsPath(filename)
+
+	if len(storage.accounts.Users) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(storage.accounts)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
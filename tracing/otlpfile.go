@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// FileExporter записывает spans в файл в формате OTLP JSON: каждая строка -
+// отдельное сообщение TracesData, как у file exporter коллектора OpenTelemetry.
+type FileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{file: file}, nil
+}
+
+func (e *FileExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	data, err := protojson.Marshal(&tracepb.TracesData{ResourceSpans: resourceSpans(spans)})
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.file == nil {
+		return os.ErrClosed
+	}
+	_, err = e.file.Write(append(data, '\n'))
+	return err
+}
+
+func (e *FileExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.file == nil {
+		return nil
+	}
+	err := e.file.Close()
+	e.file = nil
+	return err
+}
+
+// resourceSpans группирует spans по инструментирующей библиотеке. Все spans
+// одного TracerProvider имеют общий ресурс, поэтому берется ресурс первого.
+func resourceSpans(spans []sdktrace.ReadOnlySpan) []*tracepb.ResourceSpans {
+	scopes := make(map[string]*tracepb.ScopeSpans)
+	var order []*tracepb.ScopeSpans
+
+	for _, span := range spans {
+		scope := span.InstrumentationScope()
+		scopeSpans, ok := scopes[scope.Name]
+		if !ok {
+			scopeSpans = &tracepb.ScopeSpans{
+				Scope: &commonpb.InstrumentationScope{Name: scope.Name, Version: scope.Version},
+			}
+			scopes[scope.Name] = scopeSpans
+			order = append(order, scopeSpans)
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, spanProto(span))
+	}
+
+	return []*tracepb.ResourceSpans{{
+		Resource:   &resourcepb.Resource{Attributes: attributesProto(spans[0].Resource().Attributes())},
+		ScopeSpans: order,
+	}}
+}
+
+func spanProto(span sdktrace.ReadOnlySpan) *tracepb.Span {
+	sc := span.SpanContext()
+	traceID, spanID := sc.TraceID(), sc.SpanID()
+
+	result := &tracepb.Span{
+		TraceId:           traceID[:],
+		SpanId:            spanID[:],
+		TraceState:        sc.TraceState().String(),
+		Name:              span.Name(),
+		Kind:              tracepb.Span_SpanKind(span.SpanKind()),
+		StartTimeUnixNano: uint64(span.StartTime().UnixNano()),
+		EndTimeUnixNano:   uint64(span.EndTime().UnixNano()),
+		Attributes:        attributesProto(span.Attributes()),
+		Status:            statusProto(span.Status()),
+	}
+
+	if parent := span.Parent(); parent.IsValid() {
+		parentID := parent.SpanID()
+		result.ParentSpanId = parentID[:]
+	}
+
+	for _, event := range span.Events() {
+		result.Events = append(result.Events, &tracepb.Span_Event{
+			TimeUnixNano: uint64(event.Time.UnixNano()),
+			Name:         event.Name,
+			Attributes:   attributesProto(event.Attributes),
+		})
+	}
+
+	return result
+}
+
+// statusProto переводит код статуса: в OTLP Ok и Error пронумерованы
+// иначе, чем в codes.
+func statusProto(status sdktrace.Status) *tracepb.Status {
+	result := &tracepb.Status{Message: status.Description}
+	switch status.Code {
+	case codes.Ok:
+		result.Code = tracepb.Status_STATUS_CODE_OK
+	case codes.Error:
+		result.Code = tracepb.Status_STATUS_CODE_ERROR
+	}
+	return result
+}
+
+func attributesProto(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	result := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		result = append(result, &commonpb.KeyValue{Key: string(attr.Key), Value: valueProto(attr.Value)})
+	}
+	return result
+}
+
+func valueProto(value attribute.Value) *commonpb.AnyValue {
+	switch value.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: value.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: value.AsFloat64()}}
+	case attribute.STRINGSLICE:
+		values := make([]*commonpb.AnyValue, 0, len(value.AsStringSlice()))
+		for _, item := range value.AsStringSlice() {
+			values = append(values, &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: item}})
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value.Emit()}}
+	}
+}
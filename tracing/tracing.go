@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone     = "none"
+	ExporterStdout   = "stdout"
+	ExporterOTLPFile = "otlp-file"
+
+	ServiceName = "quotes"
+)
+
+type Options struct {
+	// Exporter куда отправлять spans: ExporterNone, ExporterStdout или ExporterOTLPFile.
+	Exporter string
+	// Path файл для ExporterOTLPFile.
+	Path string
+	// SampleRatio доля записываемых трасс от 0 до 1.
+	SampleRatio float64
+	// Version версия сервиса в атрибутах ресурса.
+	Version string
+}
+
+// Setup настраивает глобальные TracerProvider и распространение контекста
+// W3C traceparent. Возвращенная функция отправляет оставшиеся spans и
+// закрывает экспортер. Без экспортера spans не записываются, но traceparent
+// все равно передается дальше.
+func Setup(opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch opts.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLPFile:
+		exporter, err = NewFileExporter(opts.Path)
+	default:
+		return nil, fmt.Errorf("Неизвестный экспортер трассировки: %s", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Не удалось создать экспортер трассировки: %w", err)
+	}
+
+	res := resource.NewSchemaless(
+		semconv.ServiceName(ServiceName),
+		semconv.ServiceVersion(opts.Version),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer возвращает трассировщик сервиса от текущего глобального TracerProvider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
+
+// Start начинает внутренний span с именем name.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,109 @@
+package tracing_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"quotes/logger"
+	"quotes/middleware"
+	"quotes/storage"
+	"quotes/tracing"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func readSpans(t *testing.T, path string) []*tracepb.Span {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Не удалось открыть файл трассировки: %v", err)
+	}
+	defer file.Close()
+
+	var spans []*tracepb.Span
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	for scanner.Scan() {
+		var data tracepb.TracesData
+		if err := protojson.Unmarshal(scanner.Bytes(), &data); err != nil {
+			t.Fatalf("Некорректная строка OTLP JSON: %v", err)
+		}
+		for _, resourceSpans := range data.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				spans = append(spans, scopeSpans.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func TestTracingPropagation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+
+	shutdown, err := tracing.Setup(tracing.Options{Exporter: tracing.ExporterOTLPFile, Path: path, SampleRatio: 1})
+	if err != nil {
+		t.Fatalf("Setup вернула ошибку: %v", err)
+	}
+
+	log, err := logger.NewWithWriter(io.Discard, logger.Options{})
+	if err != nil {
+		t.Fatalf("Не удалось создать логгер: %v", err)
+	}
+	s, err := storage.CreateJSONStorage(filepath.Join(t.TempDir(), "quotes.json"), log)
+	if err != nil {
+		t.Fatalf("Не удалось создать хранилище: %v", err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(middleware.Tracing)
+	r.HandleFunc("/quotes", func(w http.ResponseWriter, r *http.Request) {
+		s.Add(r.Context(), storage.Quote{Quote: "Quote 1", Author: "Author 1"}, "")
+		w.WriteHeader(http.StatusCreated)
+	}).Methods("POST")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req := httptest.NewRequest(http.MethodPost, "/quotes", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	// Тест 1: traceparent возвращается в ответе с той же трассой
+	if !strings.Contains(rec.Header().Get("traceparent"), traceID) {
+		t.Errorf("Ожидался traceparent с трассой %s, получено: %q", traceID, rec.Header().Get("traceparent"))
+	}
+
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatalf("Не удалось завершить трассировку: %v", err)
+	}
+
+	// Тест 2: spans сервера и хранилища записаны в одну трассу
+	spans := make(map[string]*tracepb.Span)
+	for _, span := range readSpans(t, path) {
+		if hex.EncodeToString(span.TraceId) != traceID {
+			t.Errorf("span %s из чужой трассы: %x", span.Name, span.TraceId)
+		}
+		spans[span.Name] = span
+	}
+
+	server, add, lock := spans["POST /quotes"], spans["storage.add"], spans["storage.lock"]
+	if server == nil || add == nil || lock == nil {
+		t.Fatalf("Ожидались spans сервера, операции и блокировки, получено: %v", spans)
+	}
+
+	// Тест 3: span хранилища вложен в серверный, ожидание блокировки - в операцию
+	if hex.EncodeToString(server.ParentSpanId) != "00f067aa0ba902b7" || server.Kind != tracepb.Span_SPAN_KIND_SERVER {
+		t.Errorf("Серверный span должен продолжать входящую трассу: %+v", server)
+	}
+	if string(add.ParentSpanId) != string(server.SpanId) || string(lock.ParentSpanId) != string(add.SpanId) {
+		t.Errorf("Нарушена вложенность spans")
+	}
+}